@@ -27,6 +27,7 @@ import (
 // +kubebuilder:metadata:annotations=api-approved.kubernetes.io=unapproved
 // +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 // +kubebuilder:storageversion
+// +kubebuilder:subresource:status
 
 // ClusterReferenceConsumer identifies a consumer and its types of references.
 // For example, a consumer may support references from Gateways to Secrets for
@@ -36,7 +37,13 @@ type ClusterReferenceConsumer struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
 	// Subject refers to the subject that is a consumer of the referenced
-	// pattern(s).
+	// pattern(s). Its name and namespace can be Go templates, rendered with
+	// the namespace of each referencing object as {{.FromNamespace}}, e.g.
+	// "system:serviceaccount:{{.FromNamespace}}:gateway", for consumers
+	// running in the namespace of the objects they implement. No other
+	// template action is allowed.
+	//
+	// +kubebuilder:validation:XValidation:rule="self.name.matches('^([^{}]|[{][{]( *|- +)[.]FromNamespace( *| +-)[}][}])*$') && (!has(self.namespace) || self.namespace.matches('^([^{}]|[{][{]( *|- +)[.]FromNamespace( *| +-)[}][}])*$'))",message="the name and namespace can only be templated with {{.FromNamespace}}"
 	Subject Subject `json:"subject"`
 
 	// ClassNames is an optional list of applicable classes for this Consumer if
@@ -45,6 +52,23 @@ type ClusterReferenceConsumer struct {
 
 	// References describe all of the resources a consumer may refer to
 	References []ConsumerReference `json:"references"`
+
+	// Status reports whether the subject can be rendered.
+	Status ClusterReferenceConsumerStatus `json:"status,omitempty"`
+}
+
+// ConsumerConditionAccepted is the type of the condition reporting whether
+// the subject of a ClusterReferenceConsumer can be rendered. Consumers that
+// aren't accepted are skipped.
+const ConsumerConditionAccepted = "Accepted"
+
+type ClusterReferenceConsumerStatus struct {
+	// Conditions describe the state of the consumer.
+	//
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // ConsumerReference describes from which originating GroupResource to which
@@ -62,6 +86,14 @@ type ConsumerReference struct {
 	//
 	// This value must be a valid DNS label as defined per RFC-1035.
 	For string `json:"for"`
+
+	// Subresources of the targets, such as "status", that the Subject is
+	// authorized for along with the targets themselves. By default, no
+	// subresource is authorized.
+	//
+	// +listType=set
+	// +optional
+	Subresources []string `json:"subresources,omitempty"`
 }
 
 // +kubebuilder:object:root=true
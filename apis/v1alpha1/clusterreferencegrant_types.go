@@ -25,6 +25,7 @@ import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 // +kubebuilder:metadata:annotations=api-approved.kubernetes.io=unapproved
 // +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 // +kubebuilder:storageversion
+// +kubebuilder:subresource:status
 
 // ClusterReferenceGrant identifies a common form of referencing pattern. This
 // can then be used with ReferenceGrants to selectively allow references.
@@ -39,6 +40,55 @@ type ClusterReferenceGrant struct {
 	// Versions describes how references and class partitions are defined for
 	// the "From" API. Each Version string must be unique.
 	Versions []VersionedReferencePaths `json:"versions"`
+
+	// Status describes the current state of the references following this
+	// pattern.
+	Status ClusterReferenceGrantStatus `json:"status,omitempty"`
+}
+
+type ClusterReferenceGrantStatus struct {
+	// ReconciliationResults describes the Roles and RoleBindings generated for
+	// each of the references of this pattern when running in rbac mode.
+	//
+	// +listType=map
+	// +listMapKey=for
+	// +listMapKey=group
+	// +listMapKey=resource
+	// +optional
+	ReconciliationResults []ReconciliationResults `json:"reconciliationResults,omitempty"`
+}
+
+// ReconciliationResults counts the Roles and RoleBindings generated for a
+// reference, and the changes made to them by the last reconciliation that
+// changed any of them.
+type ReconciliationResults struct {
+	// Group is the group of the referenced resource.
+	Group string `json:"group"`
+
+	// Resource is the referenced resource.
+	Resource string `json:"resource"`
+
+	// For is the purpose of the reference.
+	For string `json:"for"`
+
+	// Roles is the number of Roles currently generated for this reference.
+	Roles int32 `json:"roles"`
+
+	// RoleBindings is the number of RoleBindings currently generated for this
+	// reference.
+	RoleBindings int32 `json:"roleBindings"`
+
+	RolesCreated        int32 `json:"rolesCreated"`
+	RolesUpdated        int32 `json:"rolesUpdated"`
+	RolesDeleted        int32 `json:"rolesDeleted"`
+	RoleBindingsCreated int32 `json:"roleBindingsCreated"`
+	RoleBindingsUpdated int32 `json:"roleBindingsUpdated"`
+	RoleBindingsDeleted int32 `json:"roleBindingsDeleted"`
+
+	// LastChangeTime is when generated objects for this reference were last
+	// created, updated or deleted.
+	// +optional
+	LastChangeTime *metav1.Time `json:"lastChangeTime,omitempty"`
 }
 
 type VersionedReferencePaths struct {
@@ -0,0 +1,72 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=rc,scope=Namespaced
+// +kubebuilder:metadata:annotations=api-approved.kubernetes.io=unapproved
+// +kubebuilder:printcolumn:name="Service Account",type=string,JSONPath=`.serviceAccountName`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:storageversion
+
+// ReferenceConsumer identifies a consumer running in a namespace, such as a
+// tenant-owned ingress controller, and its types of references. Unlike a
+// ClusterReferenceConsumer, it can only make a ServiceAccount of its own
+// namespace a consumer, and only of references from objects in its own
+// namespace.
+type ReferenceConsumer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// ServiceAccountName is the name of the ServiceAccount, in the namespace
+	// of the ReferenceConsumer, that is a consumer of the referenced
+	// pattern(s).
+	//
+	// +kubebuilder:validation:MinLength=1
+	ServiceAccountName string `json:"serviceAccountName"`
+
+	// References describe all of the resources the consumer may refer to.
+	References []ReferenceConsumerReference `json:"references"`
+}
+
+// ReferenceConsumerReference describes a type of reference of a
+// ReferenceConsumer. Unlike a ConsumerReference, it can't authorize
+// subresources of the targets.
+type ReferenceConsumerReference struct {
+	// From refers to the group and resource that these references originate from.
+	From GroupResource `json:"from"`
+
+	// To refers to the group and resource that these references target.
+	To GroupResource `json:"to"`
+
+	// For refers to the purpose of this reference. ClusterReferenceGrants
+	// and ReferenceGrants matching the From, To, and For of this resource
+	// will be authorized for the ServiceAccount of this resource.
+	For string `json:"for"`
+}
+
+// +kubebuilder:object:root=true
+
+// ReferenceConsumerList contains a list of ReferenceConsumer
+type ReferenceConsumerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ReferenceConsumer `json:"items"`
+}
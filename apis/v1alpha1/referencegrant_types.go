@@ -22,8 +22,12 @@ import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:shortName=rg,scope=Namespaced
 // +kubebuilder:metadata:annotations=api-approved.kubernetes.io=unapproved
+// +kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+// +kubebuilder:printcolumn:name="Expires At",type=date,JSONPath=`.expiresAt`
 // +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 // +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:validation:XValidation:rule="!has(self.notBefore) || !has(self.expiresAt) || self.notBefore < self.expiresAt",message="notBefore must be before expiresAt"
 
 // ReferenceGrant identifies namespaces of resources that are trusted to
 // reference the specified names of resources in the same namespace as the
@@ -43,6 +47,48 @@ type ReferenceGrant struct {
 	To ReferenceGrantTo `json:"to"`
 
 	For For `json:"for"`
+
+	// NotBefore is when the grant starts allowing references, e.g. at the
+	// start of a migration window. References are allowed right away when
+	// unset.
+	//
+	// +optional
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+
+	// ExpiresAt is when the grant stops allowing references, e.g. at the end
+	// of a migration window. The grant never expires when unset.
+	//
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// Status describes whether the grant currently allows references.
+	Status ReferenceGrantStatus `json:"status,omitempty"`
+}
+
+// ReferenceGrantState is whether a ReferenceGrant allows references.
+//
+// +kubebuilder:validation:Enum=Pending;Active;Expired
+type ReferenceGrantState string
+
+const (
+	// ReferenceGrantPending grants have a NotBefore in the future.
+	ReferenceGrantPending ReferenceGrantState = "Pending"
+	// ReferenceGrantActive grants allow references.
+	ReferenceGrantActive ReferenceGrantState = "Active"
+	// ReferenceGrantExpired grants have an ExpiresAt in the past.
+	ReferenceGrantExpired ReferenceGrantState = "Expired"
+)
+
+type ReferenceGrantStatus struct {
+	// State is whether the grant currently allows references.
+	//
+	// +optional
+	State ReferenceGrantState `json:"state,omitempty"`
+
+	// LastTransitionTime is when State last changed.
+	//
+	// +optional
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -0,0 +1,124 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// +genclient
+// +genclient:nonNamespaced
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=rp,scope=Cluster
+// +kubebuilder:metadata:annotations=api-approved.kubernetes.io=unapproved
+// +kubebuilder:printcolumn:name="Blocked",type=integer,JSONPath=`.status.blockedReferenceCount`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+
+// ReferencePolicy lists sensitive targets that must never be granted through
+// references, whatever ClusterReferenceGrants, ReferenceGrants and consumers
+// allow. For example, the service account token Secrets of kube-system.
+type ReferencePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Exclusions describe the targets that are never granted. A target is
+	// excluded if it matches any of them.
+	Exclusions []ReferenceExclusion `json:"exclusions"`
+
+	// Status reports the references blocked by this policy.
+	Status ReferencePolicyStatus `json:"status,omitempty"`
+}
+
+// ReferenceExclusion describes targets that are never granted. A target must
+// match every field that is set.
+//
+// +kubebuilder:validation:XValidation:rule="has(self.namespaces) || has(self.names) || has(self.labelSelector) || has(self.secretTypes)",message="at least one of namespaces, names, labelSelector or secretTypes is required"
+type ReferenceExclusion struct {
+	// To limits the exclusion to targets of a group and resource. Targets of
+	// all resources are excluded when unset.
+	//
+	// +optional
+	To *GroupResource `json:"to,omitempty"`
+
+	// Namespaces of the excluded targets.
+	//
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Names are shell patterns, such as "*-token-*", matching the names of
+	// the excluded targets. Backslash escapes are not supported.
+	//
+	// +kubebuilder:validation:XValidation:rule="self.all(p, p.matches(r'^([^][\\\\]|[[][^]\\\\]+[]])*$'))",message="names must be valid shell patterns"
+	// +optional
+	Names []string `json:"names,omitempty"`
+
+	// LabelSelector selects the excluded targets by their labels.
+	//
+	// +kubebuilder:validation:XValidation:rule="!has(self.matchExpressions) || self.matchExpressions.all(e, e.operator in ['In', 'NotIn'] ? has(e.values) && size(e.values) > 0 : e.operator in ['Exists', 'DoesNotExist'] && (!has(e.values) || size(e.values) == 0))",message="In and NotIn requirements need values, Exists and DoesNotExist requirements must have none"
+	// +optional
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+
+	// SecretTypes are the types of the excluded Secrets, such as
+	// "kubernetes.io/service-account-token". Only Secrets match them.
+	//
+	// +optional
+	SecretTypes []string `json:"secretTypes,omitempty"`
+}
+
+// PolicyConditionAccepted is the type of the condition reporting whether the
+// exclusions of a ReferencePolicy are valid. Invalid name patterns and label
+// selectors match every target.
+const PolicyConditionAccepted = "Accepted"
+
+type ReferencePolicyStatus struct {
+	// Conditions describe the state of the policy.
+	//
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+
+	// BlockedReferences are the targets this policy keeps from being
+	// granted, for each from-to-for reference. At most 100 are listed.
+	//
+	// +optional
+	BlockedReferences []BlockedReference `json:"blockedReferences,omitempty"`
+
+	// BlockedReferenceCount is the number of blocked references, including
+	// those that are not listed.
+	BlockedReferenceCount int32 `json:"blockedReferenceCount"`
+}
+
+// BlockedReference is a target that would have been granted for a
+// from-to-for reference without the policy.
+type BlockedReference struct {
+	From      GroupResource `json:"from"`
+	To        GroupResource `json:"to"`
+	For       string        `json:"for"`
+	Namespace string        `json:"namespace,omitempty"`
+	Name      string        `json:"name"`
+}
+
+// +kubebuilder:object:root=true
+
+// ReferencePolicyList contains a list of ReferencePolicy
+type ReferencePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ReferencePolicy `json:"items"`
+}
@@ -22,9 +22,11 @@ type GroupResource struct {
 }
 
 type GroupResourceNamespace struct {
-	Group     string `json:"group"`
-	Resource  string `json:"resource"`
-	Namespace string `json:"namespace"`
+	Group    string `json:"group"`
+	Resource string `json:"resource"`
+	// Namespace of the resources. It is empty for cluster-scoped resources.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
 }
 
 type For string
@@ -21,9 +21,27 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BlockedReference) DeepCopyInto(out *BlockedReference) {
+	*out = *in
+	out.From = in.From
+	out.To = in.To
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockedReference.
+func (in *BlockedReference) DeepCopy() *BlockedReference {
+	if in == nil {
+		return nil
+	}
+	out := new(BlockedReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterReferenceConsumer) DeepCopyInto(out *ClusterReferenceConsumer) {
 	*out = *in
@@ -38,8 +56,11 @@ func (in *ClusterReferenceConsumer) DeepCopyInto(out *ClusterReferenceConsumer)
 	if in.References != nil {
 		in, out := &in.References, &out.References
 		*out = make([]ConsumerReference, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterReferenceConsumer.
@@ -92,6 +113,28 @@ func (in *ClusterReferenceConsumerList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterReferenceConsumerStatus) DeepCopyInto(out *ClusterReferenceConsumerStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterReferenceConsumerStatus.
+func (in *ClusterReferenceConsumerStatus) DeepCopy() *ClusterReferenceConsumerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterReferenceConsumerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterReferenceGrant) DeepCopyInto(out *ClusterReferenceGrant) {
 	*out = *in
@@ -105,6 +148,7 @@ func (in *ClusterReferenceGrant) DeepCopyInto(out *ClusterReferenceGrant) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterReferenceGrant.
@@ -157,11 +201,38 @@ func (in *ClusterReferenceGrantList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterReferenceGrantStatus) DeepCopyInto(out *ClusterReferenceGrantStatus) {
+	*out = *in
+	if in.ReconciliationResults != nil {
+		in, out := &in.ReconciliationResults, &out.ReconciliationResults
+		*out = make([]ReconciliationResults, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterReferenceGrantStatus.
+func (in *ClusterReferenceGrantStatus) DeepCopy() *ClusterReferenceGrantStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterReferenceGrantStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConsumerReference) DeepCopyInto(out *ConsumerReference) {
 	*out = *in
 	out.From = in.From
 	out.To = in.To
+	if in.Subresources != nil {
+		in, out := &in.Subresources, &out.Subresources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsumerReference.
@@ -204,6 +275,144 @@ func (in *GroupResourceNamespace) DeepCopy() *GroupResourceNamespace {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReconciliationResults) DeepCopyInto(out *ReconciliationResults) {
+	*out = *in
+	if in.LastChangeTime != nil {
+		in, out := &in.LastChangeTime, &out.LastChangeTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReconciliationResults.
+func (in *ReconciliationResults) DeepCopy() *ReconciliationResults {
+	if in == nil {
+		return nil
+	}
+	out := new(ReconciliationResults)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferenceConsumer) DeepCopyInto(out *ReferenceConsumer) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.References != nil {
+		in, out := &in.References, &out.References
+		*out = make([]ReferenceConsumerReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceConsumer.
+func (in *ReferenceConsumer) DeepCopy() *ReferenceConsumer {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferenceConsumer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ReferenceConsumer) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferenceConsumerList) DeepCopyInto(out *ReferenceConsumerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ReferenceConsumer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceConsumerList.
+func (in *ReferenceConsumerList) DeepCopy() *ReferenceConsumerList {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferenceConsumerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ReferenceConsumerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferenceConsumerReference) DeepCopyInto(out *ReferenceConsumerReference) {
+	*out = *in
+	out.From = in.From
+	out.To = in.To
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceConsumerReference.
+func (in *ReferenceConsumerReference) DeepCopy() *ReferenceConsumerReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferenceConsumerReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferenceExclusion) DeepCopyInto(out *ReferenceExclusion) {
+	*out = *in
+	if in.To != nil {
+		in, out := &in.To, &out.To
+		*out = new(GroupResource)
+		**out = **in
+	}
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LabelSelector != nil {
+		in, out := &in.LabelSelector, &out.LabelSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretTypes != nil {
+		in, out := &in.SecretTypes, &out.SecretTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceExclusion.
+func (in *ReferenceExclusion) DeepCopy() *ReferenceExclusion {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferenceExclusion)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ReferenceGrant) DeepCopyInto(out *ReferenceGrant) {
 	*out = *in
@@ -211,6 +420,15 @@ func (in *ReferenceGrant) DeepCopyInto(out *ReferenceGrant) {
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.From = in.From
 	in.To.DeepCopyInto(&out.To)
+	if in.NotBefore != nil {
+		in, out := &in.NotBefore, &out.NotBefore
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrant.
@@ -263,6 +481,25 @@ func (in *ReferenceGrantList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferenceGrantStatus) DeepCopyInto(out *ReferenceGrantStatus) {
+	*out = *in
+	if in.LastTransitionTime != nil {
+		in, out := &in.LastTransitionTime, &out.LastTransitionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrantStatus.
+func (in *ReferenceGrantStatus) DeepCopy() *ReferenceGrantStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferenceGrantStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ReferenceGrantTo) DeepCopyInto(out *ReferenceGrantTo) {
 	*out = *in
@@ -299,6 +536,98 @@ func (in *ReferencePath) DeepCopy() *ReferencePath {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferencePolicy) DeepCopyInto(out *ReferencePolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.Exclusions != nil {
+		in, out := &in.Exclusions, &out.Exclusions
+		*out = make([]ReferenceExclusion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferencePolicy.
+func (in *ReferencePolicy) DeepCopy() *ReferencePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferencePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ReferencePolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferencePolicyList) DeepCopyInto(out *ReferencePolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ReferencePolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferencePolicyList.
+func (in *ReferencePolicyList) DeepCopy() *ReferencePolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferencePolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ReferencePolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferencePolicyStatus) DeepCopyInto(out *ReferencePolicyStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.BlockedReferences != nil {
+		in, out := &in.BlockedReferences, &out.BlockedReferences
+		*out = make([]BlockedReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferencePolicyStatus.
+func (in *ReferencePolicyStatus) DeepCopy() *ReferencePolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferencePolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Subject) DeepCopyInto(out *Subject) {
 	*out = *in
@@ -61,8 +61,12 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&ClusterReferenceConsumerList{},
 		&ClusterReferenceGrant{},
 		&ClusterReferenceGrantList{},
+		&ReferenceConsumer{},
+		&ReferenceConsumerList{},
 		&ReferenceGrant{},
 		&ReferenceGrantList{},
+		&ReferencePolicy{},
+		&ReferencePolicyList{},
 	)
 	// AddToGroupVersion allows the serialization of client types like ListOptions.
 	v1.AddToGroupVersion(scheme, SchemeGroupVersion)
@@ -21,7 +21,10 @@ import (
 	"fmt"
 
 	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/graph"
 
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -37,24 +40,24 @@ func NewClusterReferenceConsumerHandler(c *Controller) *ClusterReferenceConsumer
 	return &ClusterReferenceConsumerHandler{c: c}
 }
 
-func (h *ClusterReferenceConsumerHandler) Create(ctx context.Context, e event.CreateEvent, q workqueue.RateLimitingInterface) {
+func (h *ClusterReferenceConsumerHandler) Create(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
 	queuePatternsForCRC(e.Object, q)
 }
 
-func (h *ClusterReferenceConsumerHandler) Update(ctx context.Context, e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+func (h *ClusterReferenceConsumerHandler) Update(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
 	queuePatternsForCRC(e.ObjectNew, q)
 	queuePatternsForCRC(e.ObjectOld, q)
 }
 
-func (h *ClusterReferenceConsumerHandler) Delete(ctx context.Context, e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+func (h *ClusterReferenceConsumerHandler) Delete(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
 	queuePatternsForCRC(e.Object, q)
 }
 
-func (h *ClusterReferenceConsumerHandler) Generic(ctx context.Context, e event.GenericEvent, q workqueue.RateLimitingInterface) {
+func (h *ClusterReferenceConsumerHandler) Generic(ctx context.Context, e event.GenericEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
 	queuePatternsForCRC(e.Object, q)
 }
 
-func queuePatternsForCRC(obj client.Object, q workqueue.RateLimitingInterface) {
+func queuePatternsForCRC(obj client.Object, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
 	crc := obj.(*v1a1.ClusterReferenceConsumer)
 	name := fmt.Sprintf("ClusterReferenceConsumer/%s", crc.Name)
 
@@ -66,3 +69,33 @@ func queuePatternsForCRC(obj client.Object, q workqueue.RateLimitingInterface) {
 		q.AddRateLimited(reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: key}})
 	}
 }
+
+// updateConsumerStatus reports whether the subject of every
+// ClusterReferenceConsumer can be rendered in its Accepted condition.
+func (c *Controller) updateConsumerStatus(ctx context.Context, crcs []v1a1.ClusterReferenceConsumer) error {
+	for i := range crcs {
+		crc := crcs[i].DeepCopy()
+		condition := metav1.Condition{
+			Type:               v1a1.ConsumerConditionAccepted,
+			Status:             metav1.ConditionTrue,
+			Reason:             "Accepted",
+			ObservedGeneration: crc.Generation,
+		}
+		if err := graph.ValidateConsumer(*crc); err != nil {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "InvalidSubject"
+			condition.Message = err.Error()
+		}
+		if !meta.SetStatusCondition(&crc.Status.Conditions, condition) {
+			continue
+		}
+
+		c.log.Info("Updating ClusterReferenceConsumer status", "name", crc.Name, "accepted", condition.Status)
+		err := c.crClient.Status().Patch(ctx, crc, client.MergeFrom(&crcs[i]))
+		if client.IgnoreNotFound(err) != nil {
+			c.log.Error(err, "error updating ClusterReferenceConsumer status")
+			return err
+		}
+	}
+	return nil
+}
@@ -41,23 +41,26 @@ func NewClusterReferenceGrantHandler(c *Controller) *ClusterReferenceGrantHandle
 	}
 }
 
-func (h *ClusterReferenceGrantHandler) Create(ctx context.Context, e event.CreateEvent, q workqueue.RateLimitingInterface) {
+func (h *ClusterReferenceGrantHandler) Create(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
 	h.queueCRP(e.Object, q)
 }
 
-func (h *ClusterReferenceGrantHandler) Update(ctx context.Context, e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+func (h *ClusterReferenceGrantHandler) Update(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
 	h.queueCRP(e.ObjectNew, q)
+	// Keys of references removed or changed are recomputed too, so their
+	// edges and generated RBAC are revoked.
+	h.queueCRP(e.ObjectOld, q)
 }
 
-func (h *ClusterReferenceGrantHandler) Delete(ctx context.Context, e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+func (h *ClusterReferenceGrantHandler) Delete(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
 	h.queueCRP(e.Object, q)
 }
 
-func (h *ClusterReferenceGrantHandler) Generic(ctx context.Context, e event.GenericEvent, q workqueue.RateLimitingInterface) {
+func (h *ClusterReferenceGrantHandler) Generic(ctx context.Context, e event.GenericEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
 	h.queueCRP(e.Object, q)
 }
 
-func (h *ClusterReferenceGrantHandler) queueCRP(obj client.Object, q workqueue.RateLimitingInterface) {
+func (h *ClusterReferenceGrantHandler) queueCRP(obj client.Object, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
 	crg := obj.(*v1a1.ClusterReferenceGrant)
 	name := fmt.Sprintf("ClusterReferenceGrant/%s", crg.Name)
 
@@ -17,54 +17,138 @@ limitations under the License.
 package controller
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/client-go/util/jsonpath"
-	"k8s.io/klog/v2/klogr"
+	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2/textlogger"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	ctrlmanager "sigs.k8s.io/controller-runtime/pkg/manager"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/authzconfig"
+	"sigs.k8s.io/referencegrant-poc/pkg/config"
+	"sigs.k8s.io/referencegrant-poc/pkg/graph"
+	"sigs.k8s.io/referencegrant-poc/pkg/metrics"
+	rbaccontroller "sigs.k8s.io/referencegrant-poc/pkg/rbac-implementation/controller"
 	"sigs.k8s.io/referencegrant-poc/pkg/store"
 )
 
+// Mode selects how the computed reference graph is enforced.
+type Mode string
+
 const (
-	labelKeyPatternName = "reference.authorization.k8s.io/pattern-name"
+	// ModeWebhook serves the graph to kube-apiservers through the
+	// authorization webhook.
+	ModeWebhook Mode = config.ModeWebhook
+	// ModeRBAC materializes the graph as Roles and RoleBindings with
+	// ResourceNames, for clusters that can't configure an authorization
+	// webhook.
+	ModeRBAC Mode = config.ModeRBAC
 )
 
+// Options configure the controller.
+type Options struct {
+	Mode Mode
+	// LeaderElection elects the replica doing side-effecting work, such as
+	// RBAC materialization and status writes. Every replica builds and
+	// serves the graph.
+	LeaderElection config.LeaderElectionConfiguration
+	// Verbosity is the verbosity of the logs.
+	Verbosity int
+	// SkipNameValidation allows running several controllers in a process,
+	// e.g. in tests.
+	SkipNameValidation bool
+	// AuthorizationConfiguration, if set, is kept up to date with the
+	// ClusterReferenceGrants by every replica, e.g. for the kube-apiserver
+	// on its node.
+	AuthorizationConfiguration *config.StructuredAuthorizationConfiguration
+	// MaxChainDepth is the number of from-to-for keys a chain of references
+	// can span. Defaults to graph.DefaultMaxChainDepth.
+	MaxChainDepth int
+}
+
+// policyResyncPeriod is how often keys are recomputed while ReferencePolicies
+// select targets by their labels or Secret types.
+const policyResyncPeriod = time.Minute
+
+// targetLookupTimeout is how long a target lookup waits for the informer of
+// its resource to sync.
+const targetLookupTimeout = 10 * time.Second
+
 type Controller struct {
 	dClient  *dynamic.DynamicClient
 	crClient client.Client
 	log      logr.Logger
 	store    *store.AuthStore
+	manager  ctrlmanager.Manager
+	// targets reads the targets ReferencePolicies select by their labels or
+	// Secret types from informers, started for each To resource on first use.
+	// Only their metadata and type are cached.
+	targets client.Reader
+	// scope looks up whether the To resources of keys are cluster-scoped.
+	scope  graph.ScopeFunc
+	mapper meta.RESTMapper
+	// elected is closed once this replica is the leader, or right away
+	// without leader election.
+	elected <-chan struct{}
+	// rbac is only set in ModeRBAC.
+	rbac *rbaccontroller.Reconciler
+	// authzConfig is only set when an AuthorizationConfiguration is
+	// written.
+	authzConfig   *config.StructuredAuthorizationConfiguration
+	maxChainDepth int
+	// watcher watches the From resources of ClusterReferenceGrants, added
+	// as they are found in watched.
+	watcher   controller.Controller
+	watchedMu sync.Mutex
+	watched   sets.Set[schema.GroupResource]
 }
 
-func NewController(authStore *store.AuthStore) *Controller {
-	lConfig := textlogger.NewConfig()
+// New sets up a controller computing the graph of the cluster of kConfig
+// into authStore. Nothing runs until Start is called.
+func New(kConfig *rest.Config, authStore *store.AuthStore, opts Options) (*Controller, error) {
+	lConfig := textlogger.NewConfig(textlogger.Verbosity(opts.Verbosity))
 
 	c := &Controller{
-		log:   textlogger.NewLogger(lConfig),
-		store: authStore,
+		log:           textlogger.NewLogger(lConfig),
+		store:         authStore,
+		authzConfig:   opts.AuthorizationConfiguration,
+		maxChainDepth: opts.MaxChainDepth,
+		watched:       sets.New[schema.GroupResource](),
+	}
+	if c.maxChainDepth == 0 {
+		c.maxChainDepth = graph.DefaultMaxChainDepth
 	}
-	ctrl.SetLogger(klogr.New())
+	ctrl.SetLogger(c.log)
 
 	c.log.Info("Initializing Controller")
 
-	kConfig := ctrl.GetConfigOrDie()
 	scheme := scheme.Scheme
 	v1a1.AddToScheme(scheme)
 	gatewayv1.AddToScheme(scheme)
@@ -72,60 +156,188 @@ func NewController(authStore *store.AuthStore) *Controller {
 	dClient, err := dynamic.NewForConfig(kConfig)
 	if err != nil {
 		c.log.Error(err, "could not create Dynamic client")
-		os.Exit(1)
+		return nil, err
 	}
 
 	c.dClient = dClient
 
-	manager, err := ctrl.NewManager(kConfig, ctrl.Options{Scheme: scheme})
+	options := ctrl.Options{
+		Scheme: scheme,
+		// Manager metrics are served with our own on the webhook server.
+		Metrics: metricsserver.Options{BindAddress: "0"},
+	}
+	if opts.LeaderElection.LeaderElect {
+		options.LeaderElection = true
+		options.LeaderElectionID = opts.LeaderElection.ResourceName
+		options.LeaderElectionNamespace = opts.LeaderElection.ResourceNamespace
+		options.LeaseDuration = &opts.LeaderElection.LeaseDuration.Duration
+		options.RenewDeadline = &opts.LeaderElection.RenewDeadline.Duration
+		options.RetryPeriod = &opts.LeaderElection.RetryPeriod.Duration
+	}
+	if opts.Mode == ModeRBAC {
+		// Only cache the Roles and RoleBindings we generate.
+		generated, err := labels.NewRequirement(rbaccontroller.LabelKeyPatternName, selection.Exists, nil)
+		if err != nil {
+			c.log.Error(err, "could not create label selector")
+			return nil, err
+		}
+		selector := labels.NewSelector().Add(*generated)
+		options.Cache.ByObject = map[client.Object]cache.ByObject{
+			&rbacv1.Role{}:        {Label: selector},
+			&rbacv1.RoleBinding{}: {Label: selector},
+		}
+	}
+
+	manager, err := ctrl.NewManager(kConfig, options)
 	if err != nil {
 		c.log.Error(err, "could not create manager")
-		os.Exit(1)
+		return nil, err
 	}
 
+	c.manager = manager
+	c.elected = manager.Elected()
 	c.crClient = manager.GetClient()
+	// Targets are cached apart, holding only what exclusions match on, so
+	// that e.g. the data of Secrets is never kept in memory.
+	targets, err := cache.New(kConfig, cache.Options{
+		HTTPClient:       manager.GetHTTPClient(),
+		Scheme:           scheme,
+		Mapper:           manager.GetRESTMapper(),
+		DefaultTransform: graph.StripTarget,
+	})
+	if err != nil {
+		c.log.Error(err, "could not create target cache")
+		return nil, err
+	}
+	if err := manager.Add(targets); err != nil {
+		c.log.Error(err, "could not add target cache")
+		return nil, err
+	}
+	c.targets = targets
+	c.mapper = manager.GetRESTMapper()
+	c.scope = graph.RESTMapperScope(c.mapper)
 
-	// TODO: Add selective ClusterRole and RoleBinding watchers here
-	err = ctrl.NewControllerManagedBy(manager).
+	b := ctrl.NewControllerManagedBy(manager).
 		Named("referencegrant-poc").
-		Watches(&v1a1.ClusterReferenceConsumer{}, NewClusterReferenceConsumerHandler(c)).
-		Watches(&v1a1.ClusterReferenceGrant{}, NewClusterReferenceGrantHandler(c)).
-		Watches(&v1a1.ReferenceGrant{}, NewReferenceGrantHandler(c)).
-		Watches(&gatewayv1.Gateway{}, NewGatewayEventsHandler(c)).
-		Complete(c)
+		// Every replica builds the graph, so that all of them can serve it.
+		WithOptions(controller.Options{
+			NeedLeaderElection: ptr.To(false),
+			SkipNameValidation: ptr.To(opts.SkipNameValidation),
+		}).
+		// Status updates don't change the graph.
+		Watches(&v1a1.ClusterReferenceConsumer{}, NewClusterReferenceConsumerHandler(c), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&v1a1.ReferenceConsumer{}, NewReferenceConsumerHandler(c)).
+		// Status updates in ModeRBAC don't change the graph.
+		Watches(&v1a1.ClusterReferenceGrant{}, NewClusterReferenceGrantHandler(c), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&v1a1.ReferenceGrant{}, NewReferenceGrantHandler(c), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&v1a1.ReferencePolicy{}, NewReferencePolicyHandler(c), builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+		// The From resources of ClusterReferenceGrants are watched as they
+		// are found, see watchFromResources.
+
+	if opts.Mode == ModeRBAC {
+		c.rbac = rbaccontroller.NewReconciler(c.crClient, c.log)
+		// Requeue the key of generated Roles and RoleBindings when they
+		// change, so drift is reverted.
+		b = b.
+			Watches(&rbacv1.Role{}, NewGeneratedRBACEventsHandler(c)).
+			Watches(&rbacv1.RoleBinding{}, NewGeneratedRBACEventsHandler(c))
+
+		// Keys reconciled before this replica was elected were not
+		// materialized, so requeue all of them once elected.
+		resync := make(chan event.GenericEvent)
+		b = b.WatchesRawSource(source.Channel(resync, NewClusterReferenceGrantHandler(c)))
+
+		// Once elected, clean up generated objects of references that were
+		// removed while no leader was running. Failing to do so shouldn't
+		// stop the manager, the next election will try again.
+		err = manager.Add(ctrlmanager.RunnableFunc(func(ctx context.Context) error {
+			if err := c.cleanupOrphanedRBAC(ctx); err != nil {
+				c.log.Error(err, "could not clean up orphaned RBAC")
+			}
+			if err := c.resyncGrants(ctx, resync); err != nil {
+				c.log.Error(err, "could not requeue ClusterReferenceGrants")
+			}
+			return nil
+		}))
+		if err != nil {
+			c.log.Error(err, "could not add RBAC cleanup")
+			return nil, err
+		}
+	}
 
+	c.watcher, err = b.Build(c)
 	if err != nil {
 		c.log.Error(err, "could not setup controller")
-		os.Exit(1)
+		return nil, err
 	}
 
-	if err := manager.Start(ctrl.SetupSignalHandler()); err != nil {
+	return c, nil
+}
+
+// Add adds a runnable, e.g. a server, to run with the controller. Runnables
+// are started by Start and stopped gracefully when its context is done.
+func (c *Controller) Add(r ctrlmanager.Runnable) error {
+	return c.manager.Add(r)
+}
+
+// Start runs the controller and its runnables until ctx is done, and waits
+// for them to stop.
+func (c *Controller) Start(ctx context.Context) error {
+	if err := c.manager.Start(ctx); err != nil {
 		c.log.Error(err, "could not start manager")
-		os.Exit(1)
+		return err
+	}
+	return nil
+}
+
+// isLeader returns true if this replica does side-effecting work.
+func (c *Controller) isLeader() bool {
+	select {
+	case <-c.elected:
+		return true
+	default:
+		return false
+	}
+}
+
+// resyncGrants requeues the keys of every ClusterReferenceGrant.
+func (c *Controller) resyncGrants(ctx context.Context, resync chan<- event.GenericEvent) error {
+	crgList := &v1a1.ClusterReferenceGrantList{}
+	if err := c.crClient.List(ctx, crgList); err != nil {
+		return err
+	}
+	for i := range crgList.Items {
+		select {
+		case resync <- event.GenericEvent{Object: &crgList.Items[i]}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
+	return nil
+}
 
-	return c
+// cleanupOrphanedRBAC deletes generated Roles and RoleBindings whose
+// from-to-for key isn't defined by any ClusterReferenceGrant.
+func (c *Controller) cleanupOrphanedRBAC(ctx context.Context) error {
+	crgList := &v1a1.ClusterReferenceGrantList{}
+	err := c.crClient.List(ctx, crgList)
+	if err != nil {
+		c.log.Error(err, "could not list ClusterReferenceGrants")
+		return err
+	}
+	return c.rbac.CleanupOrphans(ctx, allKeys(crgList.Items))
 }
 
-func getApplicableKeys(crgList *v1a1.ClusterReferenceGrantList, from string) sets.Set[string] {
+// allKeys returns the from-to-for keys defined by crgs.
+func allKeys(crgs []v1a1.ClusterReferenceGrant) sets.Set[string] {
 	keys := make(sets.Set[string])
-	for _, crg := range crgList.Items {
+	for _, crg := range crgs {
 		origin := fmt.Sprintf("%s/%s", crg.From.Group, crg.From.Resource)
-		// Early exit if origin is not the same
-		if from != origin {
-			continue
-		}
-		if len(crg.Versions) == 0 {
-			continue
-		}
-		for _, ref := range crg.Versions[0].References {
-			target := fmt.Sprintf("%s/%s", ref.To.Group, ref.To.Resource)
-			key := fmt.Sprintf("%s;%s;%s", origin, target, ref.For)
-			keys.Insert(key)
-		}
+		keys = keys.Union(graph.ApplicableKeys(crgs, origin))
 	}
 	return keys
 }
+
 func (c *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	c.log.Info("Reconciling for", "name", req.NamespacedName.Name)
 
@@ -135,12 +347,21 @@ func (c *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		c.log.Error(err, "could not list ClusterReferenceConsumers")
 		return ctrl.Result{}, err
 	}
+	rcList := &v1a1.ReferenceConsumerList{}
+	err = c.crClient.List(ctx, rcList)
+	if err != nil {
+		c.log.Error(err, "could not list ReferenceConsumers")
+		return ctrl.Result{}, err
+	}
 	crgList := &v1a1.ClusterReferenceGrantList{}
 	err = c.crClient.List(ctx, crgList)
 	if err != nil {
 		c.log.Error(err, "could not list ClusterReferenceGrants")
 		return ctrl.Result{}, err
 	}
+	if err := c.watchFromResources(crgList.Items); err != nil {
+		return ctrl.Result{}, err
+	}
 
 	rgList := &v1a1.ReferenceGrantList{}
 	err = c.crClient.List(ctx, rgList)
@@ -148,208 +369,178 @@ func (c *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		c.log.Error(err, "could not list ReferenceGrants")
 		return ctrl.Result{}, err
 	}
+	rpList := &v1a1.ReferencePolicyList{}
+	err = c.crClient.List(ctx, rpList)
+	if err != nil {
+		c.log.Error(err, "could not list ReferencePolicies")
+		return ctrl.Result{}, err
+	}
 
-	// Currently when we enter the reconcile with Gateway event, we only have the "From".
-	// Hence we recalculate all applicable keys in graph with "gateway.networking.k8s.io.gateway" as "From"
+	// When we enter the reconcile with an event of a From object, we only
+	// have the "From". Hence we recalculate all applicable keys in graph
+	// with its "group/resource" as "From".
 	c.log.Info(fmt.Sprintf("req: %s", req.NamespacedName.Namespace))
 	keys := make(sets.Set[string])
-	if strings.Split(req.NamespacedName.Name, "/")[0] == "Gateway" {
-		keys = getApplicableKeys(crgList, req.NamespacedName.Namespace)
-	} else {
+	switch strings.Split(req.NamespacedName.Name, "/")[0] {
+	case "From":
+		keys = graph.ApplicableKeys(crgList.Items, req.NamespacedName.Namespace)
+	case "ReferencePolicy":
+		keys = allKeys(crgList.Items)
+	default:
 		keys.Insert(req.NamespacedName.Namespace)
 	}
+	// Keys chained to the reconciled ones inherit their subjects, so they
+	// are recomputed together.
+	keys = graph.ChainedKeys(crgList.Items, keys)
 	c.log.Info(fmt.Sprintf("keys: %v", keys))
 
-	for fromToForKey := range keys {
-		c.log.Info("Reconciling for", "name", fromToForKey)
-		crcSubjects := []v1a1.Subject{}
-		for _, crc := range crcList.Items {
-			for _, ref := range crc.References {
-				// qKey := NewQueueKey(ref.From.Group, ref.From.Resource, ref.To.Group, ref.To.Resource, ref.For)
-				origin := fmt.Sprintf("%s/%s", ref.From.Group, ref.From.Resource)
-				target := fmt.Sprintf("%s/%s", ref.To.Group, ref.To.Resource)
-				key := fmt.Sprintf("%s;%s;%s", origin, target, ref.For)
-				if key == fromToForKey {
-					if crc.Subject.Kind == "ServiceAccount" {
-						// crc.Subject.Name = apiserverserviceaccount.MakeUsername(crc.Subject.Namespace, crc.Subject.Name)
-						crc.Subject.Kind = "User"
-						crc.Subject.Name = fmt.Sprintf("system:serviceaccount:%s:%s", crc.Subject.Namespace, crc.Subject.Name)
-						crc.Subject.Namespace = ""
-					}
-					crcSubjects = append(crcSubjects, crc.Subject)
-					break
-				}
-			}
+	// Only the ReferenceGrants active now are followed. The keys are
+	// requeued for when one of their ReferenceGrants starts or expires.
+	now := time.Now()
+	activeRGs, nextTransition := graph.ActiveReferenceGrants(rgList.Items, keys, now)
+	activeRGList := &v1a1.ReferenceGrantList{Items: activeRGs}
+
+	graphs := make([]graph.KeyGraph, 0, keys.Len())
+	for _, fromToForKey := range sets.List(keys) {
+		start := time.Now()
+		kg, err := c.computeKey(ctx, fromToForKey, crcList, rcList, crgList, activeRGList, rpList)
+		metrics.ReconcileDuration.WithLabelValues(fromToForKey).Observe(time.Since(start).Seconds())
+		if err != nil {
+			metrics.ReconcileErrors.WithLabelValues(fromToForKey).Inc()
+			return ctrl.Result{}, err
 		}
-		// map between FromNamespace to a map of ToNamespace to []ResourceName for this particular fromToFor key
-		crossNamespaceGrants := map[string]map[string]sets.Set[string]{}
-		for _, rg := range rgList.Items {
-			origin := fmt.Sprintf("%s/%s", rg.From.Group, rg.From.Resource)
-			target := fmt.Sprintf("%s/%s", rg.To.Group, rg.To.Resource)
-			key := fmt.Sprintf("%s;%s;%s", origin, target, rg.For)
-			if key == fromToForKey {
-				if _, ok := crossNamespaceGrants[rg.From.Namespace]; !ok {
-					crossNamespaceGrants[rg.From.Namespace] = make(map[string]sets.Set[string])
-				}
-				if _, ok := crossNamespaceGrants[rg.From.Namespace][rg.Namespace]; !ok {
-					crossNamespaceGrants[rg.From.Namespace][rg.Namespace] = make(sets.Set[string])
-				}
-				crossNamespaceGrants[rg.From.Namespace][rg.Namespace].Insert(rg.To.Names...)
-			}
+		graphs = append(graphs, kg)
+	}
+	for _, kg := range graph.Chain(graphs, c.maxChainDepth) {
+		if err := c.applyKey(ctx, kg); err != nil {
+			metrics.ReconcileErrors.WithLabelValues(kg.Key).Inc()
+			return ctrl.Result{}, err
 		}
+	}
 
-		referencePaths := []*v1a1.ReferencePath{}
-		var fromVersion string
-		for _, crg := range crgList.Items {
-			origin := fmt.Sprintf("%s/%s", crg.From.Group, crg.From.Resource)
-			// Early exit if origin is not the same
-			if strings.Split(fromToForKey, ";")[0] != origin {
-				continue
-			}
-			if len(crg.Versions) == 0 {
-				c.log.Info("Skipping clusterReferenceGrant with no versions", "name", crg.Name)
-				continue
-			}
-			fromVersion = crg.Versions[0].Version
-			// TODO: Handle versions, currently taking only the first versions in the list
-			for _, ref := range crg.Versions[0].References {
-				target := fmt.Sprintf("%s/%s", ref.To.Group, ref.To.Resource)
-				key := fmt.Sprintf("%s;%s;%s", origin, target, ref.For)
-				if key == fromToForKey {
-					referencePaths = append(referencePaths, &ref)
-				}
-			}
+	if c.authzConfig != nil {
+		written, err := authzconfig.WriteFile(c.authzConfig.Path, authzconfig.Generate(crgList.Items, *c.authzConfig))
+		if err != nil {
+			c.log.Error(err, "could not write AuthorizationConfiguration", "path", c.authzConfig.Path)
+			return ctrl.Result{}, err
 		}
-
-		// At this point, all references declaration in ReferencePaths are relevant for us.
-		// Follow Reference Paths to recalculate the graph.
-		origin := strings.Split(strings.Split(fromToForKey, ";")[0], "/")
-		fromGroup, fromResource := origin[0], origin[1]
-
-		var referencedResources []reference
-		for _, refPath := range referencePaths {
-			// TODO: Have informers for each target resource of a ClusterReferenceGrant
-			targetGVR := schema.GroupVersionResource{Group: fromGroup, Version: fromVersion, Resource: fromResource}
-			targetList, err := c.dClient.Resource(targetGVR).List(context.TODO(), metav1.ListOptions{})
-			if err != nil {
-				c.log.Error(err, "failed to list target for ClusterReferenceGrant", targetGVR)
-				return ctrl.Result{}, err
-			}
-			refResources, err := c.getReferences(targetList, refPath.Path)
-			if err != nil {
-				c.log.Error(err, "failed to follow references for path", refPath.Path, targetGVR)
-				return ctrl.Result{}, err
-			}
-			referencedResources = append(referencedResources, refResources...)
+		if written {
+			c.log.Info("AuthorizationConfiguration updated", "path", c.authzConfig.Path)
 		}
+	}
 
-		// For the first POC, we want to recalculate the from-to-for key e2e. Clearing the key first
-		c.store.ClearGraphKey(fromToForKey)
-
-		finalRefResources := []reference{}
-		for _, refResource := range referencedResources {
-			if refResource.FromNamespace != refResource.ToNamespace {
-				if allowedToNamespaces, ok := crossNamespaceGrants[refResource.FromNamespace]; ok {
-					if allowedNamesForNamespace, exist := allowedToNamespaces[refResource.ToNamespace]; exist {
-						if allowedNamesForNamespace.Has(refResource.Name) {
-							finalRefResources = append(finalRefResources, refResource)
-						}
-					}
-				}
-			} else {
-				finalRefResources = append(finalRefResources, refResource)
-			}
+	if c.isLeader() {
+		if err := c.updateConsumerStatus(ctx, crcList.Items); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := c.updatePolicyStatus(ctx, rpList.Items); err != nil {
+			return ctrl.Result{}, err
 		}
-		for _, refResource := range finalRefResources {
-			c.store.UpsertGrant(fromToForKey, types.NamespacedName{Namespace: refResource.ToNamespace, Name: refResource.Name}, crcSubjects)
+		if err := c.updateGrantStatus(ctx, rgList.Items, now); err != nil {
+			return ctrl.Result{}, err
 		}
-		c.log.V(0).Info("Reconciliation finished", "GraphKey", fromToForKey)
-		c.log.V(0).Info(fmt.Sprintf("Graph is:\n%v\n", c.store.GetGraph()))
-		c.log.V(0).Info(fmt.Sprintf("SubjectIndex is: %v\n", c.store.GetSubjectIndex()))
 	}
 
-	return ctrl.Result{}, nil
-}
+	metrics.ObserveGraph(c.store)
 
-func generateQueueKey(fromGroup, fromResource, toGroup, toResource, forReason string) types.NamespacedName {
-	nn := types.NamespacedName{Name: forReason}
-	nn.Namespace = fmt.Sprintf("%s/%s-%s/%s")
-	return nn
+	result := ctrl.Result{}
+	if !nextTransition.IsZero() {
+		result.RequeueAfter = nextTransition.Sub(now)
+	}
+	// Changes to targets don't trigger reconciles, so keys are recomputed
+	// periodically, from the cache, while ReferencePolicies select targets
+	// by their labels or Secret types. This also retries failed lookups.
+	if graph.NeedsTargets(rpList.Items) && (result.RequeueAfter == 0 || result.RequeueAfter > policyResyncPeriod) {
+		result.RequeueAfter = policyResyncPeriod
+	}
+	return result, nil
 }
 
-type reference struct {
-	Group         string
-	Resource      string
-	FromNamespace string
-	ToNamespace   string
-	Name          string
-}
+// computeKey computes the graph for a single from-to-for key, less the
+// targets excluded by ReferencePolicies, before it is chained.
+func (c *Controller) computeKey(ctx context.Context, fromToForKey string, crcList *v1a1.ClusterReferenceConsumerList, rcList *v1a1.ReferenceConsumerList, crgList *v1a1.ClusterReferenceGrantList, rgList *v1a1.ReferenceGrantList, rpList *v1a1.ReferencePolicyList) (graph.KeyGraph, error) {
+	c.log.Info("Reconciling for", "name", fromToForKey)
 
-func (c *Controller) getReferences(list *unstructured.UnstructuredList, path string) ([]reference, error) {
-	refs := []reference{}
-	for _, item := range list.Items {
-		j := jsonpath.New("test").AllowMissingKeys(true)
-		err := j.Parse(fmt.Sprintf("{%s}", path))
-		if err != nil {
-			c.log.Error(err, "error parsing JSON Path")
-			return refs, err
-		}
-		results := new(bytes.Buffer)
-		err = j.Execute(results, item.UnstructuredContent())
+	var objects []unstructured.Unstructured
+	// TODO: Have informers for each target resource of a ClusterReferenceGrant
+	if fromGVR, ok := graph.FromResource(fromToForKey, crgList.Items); ok {
+		fromList, err := c.dClient.Resource(fromGVR).List(ctx, metav1.ListOptions{})
 		if err != nil {
-			c.log.Error(err, "error finding results with JSON Path")
-			return refs, err
+			c.log.Error(err, "failed to list From resource for ClusterReferenceGrant", "gvr", fromGVR)
+			return graph.KeyGraph{}, err
 		}
+		objects = fromList.Items
+	}
+	metrics.FromObjectsScanned.WithLabelValues(fromToForKey).Set(float64(len(objects)))
 
-		rawRefs := strings.Split(results.String(), " ")
+	toClusterScoped, err := c.scope(graph.ToResource(fromToForKey))
+	if err != nil {
+		c.log.Error(err, "failed to look up the scope of the To resource", "GraphKey", fromToForKey)
+		return graph.KeyGraph{}, err
+	}
 
-		for _, rr := range rawRefs {
-			jr := map[string]string{}
-			if len(rr) == 0 {
-				continue
-			}
-			err = json.Unmarshal([]byte(rr), &jr)
-			if err != nil {
-				c.log.Error(err, "error finding results with JSON Path")
-			}
-			// The part below is commented in favour of the decision to
-			// requiring the ClusterRefGrant to specify the group and resource and
-			// limit the json path to only pull names that match that group and resource or kind.
-			// This is not feasible using the current jsonPath implementation so we are likely to use CEL for this.
-
-			// group, hasGroup := jr["group"]
-			// if !hasGroup {
-			// 	c.log.Info("Missing group in reference", "ref", jr)
-			// 	continue
-			// }
-			// resource, hasResource := jr["resource"]
-			// if !hasResource {
-			// 	kind, hasKind := jr["kind"]
-			// 	if !hasKind {
-			// 		c.log.Info("Missing kind or resource in reference", "ref", jr)
-			// 		continue
-			// 	}
-			// 	gvr, _ := meta.UnsafeGuessKindToResource(schema.GroupVersionKind{Group: group, Version: "v1", Kind: kind})
-			// 	resource = gvr.Resource
-			// }
-
-			namespace, hasNamespace := jr["namespace"]
-			if !hasNamespace {
-				namespace = item.GetNamespace()
-			}
+	kg, err := graph.ComputeKey(fromToForKey, toClusterScoped, crcList.Items, rcList.Items, crgList.Items, rgList.Items, objects)
+	if err != nil {
+		c.log.Error(err, "failed to compute graph", "GraphKey", fromToForKey)
+		return graph.KeyGraph{}, err
+	}
+	for name, err := range kg.SkippedConsumers {
+		c.log.Error(err, "skipping ClusterReferenceConsumer with an invalid subject", "name", name, "GraphKey", fromToForKey)
+	}
+	return graph.Exclude(kg, rpList.Items, c.targetFunc(ctx)), nil
+}
 
-			name, hasName := jr["name"]
-			if !hasName {
-				c.log.Info("Missing name in reference", "ref", jr)
-				continue
-			}
-			refs = append(refs, reference{
-				FromNamespace: item.GetNamespace(),
-				ToNamespace:   namespace,
-				Name:          name,
-			})
+// targetFunc returns a graph.TargetFunc getting targets from the cache. A
+// lookup that fails is logged and its target excluded. Other targets of the
+// same resource then fail right away, rather than waiting for an informer
+// that can't sync.
+func (c *Controller) targetFunc(ctx context.Context) graph.TargetFunc {
+	failed := map[schema.GroupResource]error{}
+	return func(gr schema.GroupResource, target types.NamespacedName) (*unstructured.Unstructured, error) {
+		if err, ok := failed[gr]; ok {
+			return nil, err
+		}
+		obj, err := c.getTarget(ctx, gr, target)
+		if err != nil {
+			c.log.Error(err, "could not look up target, excluding it", "resource", gr, "target", target)
+			failed[gr] = err
+			return nil, err
 		}
+		return obj, nil
+	}
+}
+
+func (c *Controller) getTarget(ctx context.Context, gr schema.GroupResource, target types.NamespacedName) (*unstructured.Unstructured, error) {
+	gvk, err := c.mapper.KindFor(gr.WithVersion(""))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, targetLookupTimeout)
+	defer cancel()
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	err = c.targets.Get(ctx, target, obj)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
 	}
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
 
-	return refs, nil
+// applyKey replaces the graph of a from-to-for key in the store, and
+// materializes it in ModeRBAC.
+func (c *Controller) applyKey(ctx context.Context, kg graph.KeyGraph) error {
+	c.store.ReplaceGraphKey(kg.Key, kg.Edges, kg.Provenance)
+	// Only the leader materializes the graph, the other replicas serve it.
+	if c.rbac != nil && c.isLeader() {
+		err := c.rbac.ReconcileKey(ctx, kg.Key, c.store.GetGraphKey(kg.Key), kg.Owners)
+		if err != nil {
+			c.log.Error(err, "failed to reconcile RBAC", "GraphKey", kg.Key)
+			return err
+		}
+	}
+	c.log.V(0).Info("Reconciliation finished", "GraphKey", kg.Key)
+	return nil
 }
@@ -0,0 +1,111 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+)
+
+// FromEventsHandler queues the From resource of objects referencing targets,
+// namespaced or cluster-scoped, so that every key it is the From resource of
+// is recomputed, along with the keys chained to them.
+type FromEventsHandler struct {
+	c      *Controller
+	logger logr.Logger
+	// from is the "group/resource" of the watched objects.
+	from string
+	kind string
+}
+
+func NewFromEventsHandler(c *Controller, from schema.GroupResource, kind string) *FromEventsHandler {
+	return &FromEventsHandler{
+		c:      c,
+		logger: c.log.WithName("eventHandlers").WithName("from").WithValues("resource", from),
+		from:   fmt.Sprintf("%s/%s", from.Group, from.Resource),
+		kind:   kind,
+	}
+}
+
+func (h *FromEventsHandler) Create(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	h.queueFrom(q)
+}
+
+func (h *FromEventsHandler) Update(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	h.queueFrom(q)
+}
+
+func (h *FromEventsHandler) Delete(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	h.queueFrom(q)
+}
+
+func (h *FromEventsHandler) Generic(ctx context.Context, e event.GenericEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	h.queueFrom(q)
+}
+
+// queueFrom queues a single request per From resource, as every key of the
+// resource is recomputed from all of its objects anyway.
+func (h *FromEventsHandler) queueFrom(q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	name := fmt.Sprintf("From/%s", h.kind)
+	q.AddRateLimited(reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: h.from}})
+}
+
+// watchFromResources starts watching the From resources of crgs that aren't
+// watched yet. Only the metadata of their objects is cached, since any change
+// to them is enough to recompute their keys, which list them in full.
+// Resources that can't be mapped yet, e.g. before their CRD is installed, are
+// retried on the next reconcile.
+func (c *Controller) watchFromResources(crgs []v1a1.ClusterReferenceGrant) error {
+	c.watchedMu.Lock()
+	defer c.watchedMu.Unlock()
+	for _, crg := range crgs {
+		// We ignore multiple versions for now
+		if len(crg.Versions) == 0 {
+			continue
+		}
+		from := schema.GroupResource{Group: crg.From.Group, Resource: crg.From.Resource}
+		if c.watched.Has(from) {
+			continue
+		}
+		gvk, err := c.mapper.KindFor(from.WithVersion(crg.Versions[0].Version))
+		if err != nil {
+			c.log.Error(err, "could not watch From resource, retrying on the next reconcile", "resource", from)
+			continue
+		}
+		obj := &metav1.PartialObjectMetadata{}
+		obj.SetGroupVersionKind(gvk)
+		err = c.watcher.Watch(source.Kind[client.Object](c.manager.GetCache(), obj, NewFromEventsHandler(c, from, gvk.Kind)))
+		if err != nil {
+			c.log.Error(err, "could not watch From resource", "resource", from)
+			return err
+		}
+		c.log.Info("Watching From resource", "resource", from)
+		c.watched.Insert(from)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	rbaccontroller "sigs.k8s.io/referencegrant-poc/pkg/rbac-implementation/controller"
+)
+
+// GeneratedRBACEventsHandler requeues the from-to-for key of Roles and
+// RoleBindings generated in ModeRBAC, so that changes made to them outside of
+// the controller are reverted.
+type GeneratedRBACEventsHandler struct {
+	c      *Controller
+	logger logr.Logger
+}
+
+func NewGeneratedRBACEventsHandler(c *Controller) *GeneratedRBACEventsHandler {
+	return &GeneratedRBACEventsHandler{
+		c:      c,
+		logger: c.log.WithName("eventHandlers").WithName("rbac"),
+	}
+}
+
+func (h *GeneratedRBACEventsHandler) Create(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	h.queueKey(e.Object, q)
+}
+
+func (h *GeneratedRBACEventsHandler) Update(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	h.queueKey(e.ObjectNew, q)
+}
+
+func (h *GeneratedRBACEventsHandler) Delete(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	h.queueKey(e.Object, q)
+}
+
+func (h *GeneratedRBACEventsHandler) Generic(ctx context.Context, e event.GenericEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	h.queueKey(e.Object, q)
+}
+
+func (h *GeneratedRBACEventsHandler) queueKey(obj client.Object, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	key, ok := obj.GetAnnotations()[rbaccontroller.AnnotationKeyPattern]
+	if !ok {
+		h.logger.Info("Skipping generated object without pattern annotation", "namespace", obj.GetNamespace(), "name", obj.GetName())
+		return
+	}
+	name := fmt.Sprintf("RBAC/%s/%s", obj.GetNamespace(), obj.GetName())
+	q.AddRateLimited(reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: key}})
+}
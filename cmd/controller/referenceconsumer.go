@@ -0,0 +1,68 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+type ReferenceConsumerHandler struct {
+	c *Controller
+}
+
+func NewReferenceConsumerHandler(c *Controller) *ReferenceConsumerHandler {
+	return &ReferenceConsumerHandler{c: c}
+}
+
+func (h *ReferenceConsumerHandler) Create(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	queuePatternsForRC(e.Object, q)
+}
+
+func (h *ReferenceConsumerHandler) Update(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	queuePatternsForRC(e.ObjectNew, q)
+	queuePatternsForRC(e.ObjectOld, q)
+}
+
+func (h *ReferenceConsumerHandler) Delete(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	queuePatternsForRC(e.Object, q)
+}
+
+func (h *ReferenceConsumerHandler) Generic(ctx context.Context, e event.GenericEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	queuePatternsForRC(e.Object, q)
+}
+
+func queuePatternsForRC(obj client.Object, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	rc := obj.(*v1a1.ReferenceConsumer)
+	name := fmt.Sprintf("ReferenceConsumer/%s/%s", rc.Namespace, rc.Name)
+
+	// Queue strings of "from;to;for"
+	for _, ref := range rc.References {
+		origin := fmt.Sprintf("%s/%s", ref.From.Group, ref.From.Resource)
+		target := fmt.Sprintf("%s/%s", ref.To.Group, ref.To.Resource)
+		key := fmt.Sprintf("%s;%s;%s", origin, target, ref.For)
+		q.AddRateLimited(reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: key}})
+	}
+}
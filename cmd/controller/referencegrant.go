@@ -19,13 +19,16 @@ package controller
 import (
 	"context"
 	"fmt"
+	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/graph"
 )
 
 type ReferenceGrantHandler struct {
@@ -36,24 +39,24 @@ func NewReferenceGrantHandler(c *Controller) *ReferenceGrantHandler {
 	return &ReferenceGrantHandler{c: c}
 }
 
-func (h *ReferenceGrantHandler) Create(ctx context.Context, e event.CreateEvent, q workqueue.RateLimitingInterface) {
+func (h *ReferenceGrantHandler) Create(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
 	queuePatternForRG(e.Object, q)
 }
 
-func (h *ReferenceGrantHandler) Update(ctx context.Context, e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+func (h *ReferenceGrantHandler) Update(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
 	queuePatternForRG(e.ObjectNew, q)
 	queuePatternForRG(e.ObjectOld, q)
 }
 
-func (h *ReferenceGrantHandler) Delete(ctx context.Context, e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+func (h *ReferenceGrantHandler) Delete(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
 	queuePatternForRG(e.Object, q)
 }
 
-func (h *ReferenceGrantHandler) Generic(ctx context.Context, e event.GenericEvent, q workqueue.RateLimitingInterface) {
+func (h *ReferenceGrantHandler) Generic(ctx context.Context, e event.GenericEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
 	queuePatternForRG(e.Object, q)
 }
 
-func queuePatternForRG(obj client.Object, q workqueue.RateLimitingInterface) {
+func queuePatternForRG(obj client.Object, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
 	rg := obj.(*v1a1.ReferenceGrant)
 	name := fmt.Sprintf("ReferenceGrant/%s", rg.Name)
 	origin := fmt.Sprintf("%s/%s", rg.From.Group, rg.From.Resource)
@@ -61,3 +64,25 @@ func queuePatternForRG(obj client.Object, q workqueue.RateLimitingInterface) {
 	key := fmt.Sprintf("%s;%s;%s", origin, target, rg.For)
 	q.AddRateLimited(reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: key}})
 }
+
+// updateGrantStatus reports whether every ReferenceGrant allows references at
+// now in its status.
+func (c *Controller) updateGrantStatus(ctx context.Context, rgs []v1a1.ReferenceGrant, now time.Time) error {
+	for i := range rgs {
+		state := graph.ReferenceGrantState(rgs[i], now)
+		if rgs[i].Status.State == state {
+			continue
+		}
+		rg := rgs[i].DeepCopy()
+		rg.Status.State = state
+		rg.Status.LastTransitionTime = &metav1.Time{Time: now}
+
+		c.log.Info("Updating ReferenceGrant status", "namespace", rg.Namespace, "name", rg.Name, "state", state)
+		err := c.crClient.Status().Patch(ctx, rg, client.MergeFrom(&rgs[i]))
+		if client.IgnoreNotFound(err) != nil {
+			c.log.Error(err, "error updating ReferenceGrant status")
+			return err
+		}
+	}
+	return nil
+}
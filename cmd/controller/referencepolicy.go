@@ -0,0 +1,130 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/graph"
+)
+
+// maxBlockedReferences is the number of blocked references listed in the
+// status of a ReferencePolicy.
+const maxBlockedReferences = 100
+
+type ReferencePolicyHandler struct {
+	c      *Controller
+	logger logr.Logger
+}
+
+func NewReferencePolicyHandler(c *Controller) *ReferencePolicyHandler {
+	return &ReferencePolicyHandler{
+		c:      c,
+		logger: c.log.WithName("eventHandlers").WithName("referencepolicy"),
+	}
+}
+
+func (h *ReferencePolicyHandler) Create(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	h.queueRP(e.Object, q)
+}
+
+func (h *ReferencePolicyHandler) Update(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	h.queueRP(e.ObjectNew, q)
+}
+
+func (h *ReferencePolicyHandler) Delete(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	h.queueRP(e.Object, q)
+}
+
+func (h *ReferencePolicyHandler) Generic(ctx context.Context, e event.GenericEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	h.queueRP(e.Object, q)
+}
+
+// queueRP queues a request that Reconcile expands to every from-to-for key,
+// as a ReferencePolicy may exclude targets of any of them. Listing the keys
+// there, rather than here, retries the ReferencePolicy if that fails.
+func (h *ReferencePolicyHandler) queueRP(obj client.Object, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	rp := obj.(*v1a1.ReferencePolicy)
+	name := fmt.Sprintf("ReferencePolicy/%s", rp.Name)
+	q.AddRateLimited(reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+}
+
+// updatePolicyStatus reports the references blocked by every ReferencePolicy
+// in its status, from the graph in the store, along with whether its
+// exclusions are valid.
+func (c *Controller) updatePolicyStatus(ctx context.Context, policies []v1a1.ReferencePolicy) error {
+	blocked := c.store.BlockedTargets()
+	for i := range policies {
+		rp := policies[i].DeepCopy()
+		status := v1a1.ReferencePolicyStatus{Conditions: rp.Status.Conditions}
+		condition := metav1.Condition{
+			Type:               v1a1.PolicyConditionAccepted,
+			Status:             metav1.ConditionTrue,
+			Reason:             "Accepted",
+			ObservedGeneration: rp.Generation,
+		}
+		if err := graph.ValidatePolicy(*rp); err != nil {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "InvalidExclusions"
+			condition.Message = err.Error()
+		}
+		meta.SetStatusCondition(&status.Conditions, condition)
+		for _, key := range sets.List(sets.KeySet(blocked[rp.Name])) {
+			parts := strings.Split(key, ";")
+			fromGroup, fromResource, _ := strings.Cut(parts[0], "/")
+			to := graph.ToResource(key)
+			for _, target := range blocked[rp.Name][key] {
+				status.BlockedReferenceCount++
+				if len(status.BlockedReferences) == maxBlockedReferences {
+					continue
+				}
+				status.BlockedReferences = append(status.BlockedReferences, v1a1.BlockedReference{
+					From:      v1a1.GroupResource{Group: fromGroup, Resource: fromResource},
+					To:        v1a1.GroupResource{Group: to.Group, Resource: to.Resource},
+					For:       parts[2],
+					Namespace: target.Namespace,
+					Name:      target.Name,
+				})
+			}
+		}
+		if equality.Semantic.DeepEqual(policies[i].Status, status) {
+			continue
+		}
+		rp.Status = status
+
+		c.log.Info("Updating ReferencePolicy status", "name", rp.Name, "accepted", condition.Status)
+		err := c.crClient.Status().Patch(ctx, rp, client.MergeFrom(&policies[i]))
+		if client.IgnoreNotFound(err) != nil {
+			c.log.Error(err, "error updating ReferencePolicy status")
+			return err
+		}
+	}
+	return nil
+}
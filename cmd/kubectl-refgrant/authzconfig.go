@@ -0,0 +1,97 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	clientconfig "sigs.k8s.io/controller-runtime/pkg/client/config"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/authzconfig"
+	"sigs.k8s.io/referencegrant-poc/pkg/config"
+	"sigs.k8s.io/yaml"
+)
+
+func newAuthorizationConfigCommand(o *options) *cobra.Command {
+	cfg := config.StructuredAuthorizationConfiguration{}
+	cmd := &cobra.Command{
+		Use:   "authorization-config",
+		Short: "Generate the kube-apiserver webhook authorizer calling the authorizer",
+		Long: `Generate the webhook authorizer of a kube-apiserver AuthorizationConfiguration
+calling the authorizer only for the group/resources targeted by
+ClusterReferenceGrants. The grants are read from the manifests given with -f,
+or else from the current cluster.
+
+The entry is printed, to be added to the authorizers of the configuration, e.g.
+between the Node and RBAC authorizers. With --write, it is merged into an
+existing configuration file instead, keeping the other authorizers.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			crgs, err := o.grants(cmd.Context())
+			if err != nil {
+				return err
+			}
+			webhook := authzconfig.Generate(crgs, cfg)
+			if cfg.Path != "" {
+				_, err := authzconfig.WriteFile(cfg.Path, webhook)
+				return err
+			}
+			if o.output == "json" {
+				return printJSON(webhook)
+			}
+			data, err := yaml.Marshal([]authzconfig.AuthorizerConfiguration{webhook})
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(data)
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&cfg.KubeConfigFile, "webhook-kubeconfig", "", "Path, on kube-apiservers, to the kubeconfig used to call the webhook")
+	cmd.Flags().StringVar(&cfg.WebhookName, "webhook-name", authzconfig.DefaultWebhookName, "Name of the webhook authorizer")
+	cmd.Flags().DurationVar(&cfg.Timeout.Duration, "timeout", authzconfig.DefaultTimeout, "How long kube-apiservers wait for the webhook")
+	cmd.Flags().StringVar(&cfg.Path, "write", "", "Merge the webhook into this AuthorizationConfiguration file, only if it changed, instead of printing it")
+	cmd.MarkFlagRequired("webhook-kubeconfig")
+	return cmd
+}
+
+// grants returns the ClusterReferenceGrants of the manifests, or of the
+// current cluster.
+func (o *options) grants(ctx context.Context) ([]v1a1.ClusterReferenceGrant, error) {
+	if o.manifests != nil {
+		return o.manifests.grants, nil
+	}
+	kConfig, err := clientconfig.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("could not load cluster configuration: %w", err)
+	}
+	v1a1.AddToScheme(scheme.Scheme)
+	c, err := client.New(kConfig, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		return nil, err
+	}
+	crgList := &v1a1.ClusterReferenceGrantList{}
+	if err := c.List(ctx, crgList); err != nil {
+		return nil, err
+	}
+	return crgList.Items, nil
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/graph"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+// manifests are the objects loaded from disk for a dry run.
+type manifests struct {
+	consumers          []v1a1.ClusterReferenceConsumer
+	referenceConsumers []v1a1.ReferenceConsumer
+	grants             []v1a1.ClusterReferenceGrant
+	referenceGrants    []v1a1.ReferenceGrant
+	policies           []v1a1.ReferencePolicy
+	// objects are all other objects.
+	objects []unstructured.Unstructured
+
+	// byResource indexes objects by resource, and scopes the resources of
+	// their kinds, once the manifests are loaded.
+	byResource map[schema.GroupResource][]unstructured.Unstructured
+	scopes     map[schema.GroupResource]bool
+	// warnings are the problems computing the graph ran into that don't fail
+	// it, e.g. targets missing from the manifests.
+	warnings sets.Set[string]
+}
+
+// loadManifests loads the manifests in paths for a dry run.
+func loadManifests(paths []string) (*manifests, error) {
+	m := &manifests{}
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			// Directories are walked for manifests, files are always loaded.
+			if file != path {
+				switch filepath.Ext(file) {
+				case ".yaml", ".yml", ".json":
+				default:
+					return nil
+				}
+			}
+			return m.load(file)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// store computes the graph of the manifests the same way the controller
+// does, without a cluster.
+func (m *manifests) store() (*store.AuthStore, error) {
+	// Only the ReferenceGrants active now are followed, as by the controller.
+	m.index()
+	referenceGrants, _ := graph.ActiveReferenceGrants(m.referenceGrants, nil, time.Now())
+	graphs, err := graph.Compute(m.consumers, m.referenceConsumers, m.grants, referenceGrants, m.policies, m.byResource, m.clusterScoped, m.target, graph.DefaultMaxChainDepth)
+	if err != nil {
+		return nil, err
+	}
+	s := store.NewAuthStore()
+	for _, kg := range graphs {
+		s.ReplaceGraphKey(kg.Key, kg.Edges, kg.Provenance)
+	}
+	return s, nil
+}
+
+// load decodes every object of a multi-document YAML or JSON file.
+func (m *manifests) load(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	decoder := utilyaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		obj := unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to decode %s: %w", file, err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if err := m.add(obj); err != nil {
+			return fmt.Errorf("failed to load %s %s from %s: %w", obj.GetKind(), obj.GetName(), file, err)
+		}
+	}
+}
+
+func (m *manifests) add(obj unstructured.Unstructured) error {
+	gvk := obj.GroupVersionKind()
+	if gvk.Group == v1a1.GroupName {
+		var err error
+		switch gvk.Kind {
+		case "ClusterReferenceConsumer":
+			crc := v1a1.ClusterReferenceConsumer{}
+			err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &crc)
+			m.consumers = append(m.consumers, crc)
+		case "ReferenceConsumer":
+			rc := v1a1.ReferenceConsumer{}
+			err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &rc)
+			m.referenceConsumers = append(m.referenceConsumers, rc)
+		case "ClusterReferenceGrant":
+			crg := v1a1.ClusterReferenceGrant{}
+			err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &crg)
+			m.grants = append(m.grants, crg)
+		case "ReferenceGrant":
+			rg := v1a1.ReferenceGrant{}
+			err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &rg)
+			m.referenceGrants = append(m.referenceGrants, rg)
+		case "ReferencePolicy":
+			rp := v1a1.ReferencePolicy{}
+			err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &rp)
+			m.policies = append(m.policies, rp)
+		default:
+			err = fmt.Errorf("unknown kind")
+		}
+		return err
+	}
+	m.objects = append(m.objects, obj)
+	return nil
+}
+
+// resource is the resource objects of a kind are served as.
+type resource struct {
+	schema.GroupResource
+	clusterScoped bool
+}
+
+// builtinResources are the resources of the built-in and Gateway API kinds
+// that are commonly the From or To of references. Other kinds need their
+// CustomResourceDefinition in the manifests.
+var builtinResources = map[schema.GroupKind]resource{
+	{Kind: "ConfigMap"}:                                        {schema.GroupResource{Resource: "configmaps"}, false},
+	{Kind: "Endpoints"}:                                        {schema.GroupResource{Resource: "endpoints"}, false},
+	{Kind: "Namespace"}:                                        {schema.GroupResource{Resource: "namespaces"}, true},
+	{Kind: "Node"}:                                             {schema.GroupResource{Resource: "nodes"}, true},
+	{Kind: "PersistentVolume"}:                                 {schema.GroupResource{Resource: "persistentvolumes"}, true},
+	{Kind: "PersistentVolumeClaim"}:                            {schema.GroupResource{Resource: "persistentvolumeclaims"}, false},
+	{Kind: "Pod"}:                                              {schema.GroupResource{Resource: "pods"}, false},
+	{Kind: "Secret"}:                                           {schema.GroupResource{Resource: "secrets"}, false},
+	{Kind: "Service"}:                                          {schema.GroupResource{Resource: "services"}, false},
+	{Kind: "ServiceAccount"}:                                   {schema.GroupResource{Resource: "serviceaccounts"}, false},
+	{Group: "apps", Kind: "DaemonSet"}:                         {schema.GroupResource{Group: "apps", Resource: "daemonsets"}, false},
+	{Group: "apps", Kind: "Deployment"}:                        {schema.GroupResource{Group: "apps", Resource: "deployments"}, false},
+	{Group: "apps", Kind: "StatefulSet"}:                       {schema.GroupResource{Group: "apps", Resource: "statefulsets"}, false},
+	{Group: "networking.k8s.io", Kind: "Ingress"}:              {schema.GroupResource{Group: "networking.k8s.io", Resource: "ingresses"}, false},
+	{Group: "networking.k8s.io", Kind: "IngressClass"}:         {schema.GroupResource{Group: "networking.k8s.io", Resource: "ingressclasses"}, true},
+	{Group: "node.k8s.io", Kind: "RuntimeClass"}:               {schema.GroupResource{Group: "node.k8s.io", Resource: "runtimeclasses"}, true},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:  {schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "clusterroles"}, true},
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"}:        {schema.GroupResource{Group: "scheduling.k8s.io", Resource: "priorityclasses"}, true},
+	{Group: "storage.k8s.io", Kind: "StorageClass"}:            {schema.GroupResource{Group: "storage.k8s.io", Resource: "storageclasses"}, true},
+	{Group: "gateway.networking.k8s.io", Kind: "Gateway"}:      {schema.GroupResource{Group: "gateway.networking.k8s.io", Resource: "gateways"}, false},
+	{Group: "gateway.networking.k8s.io", Kind: "GatewayClass"}: {schema.GroupResource{Group: "gateway.networking.k8s.io", Resource: "gatewayclasses"}, true},
+	{Group: "gateway.networking.k8s.io", Kind: "GRPCRoute"}:    {schema.GroupResource{Group: "gateway.networking.k8s.io", Resource: "grpcroutes"}, false},
+	{Group: "gateway.networking.k8s.io", Kind: "HTTPRoute"}:    {schema.GroupResource{Group: "gateway.networking.k8s.io", Resource: "httproutes"}, false},
+	{Group: "gateway.networking.k8s.io", Kind: "TCPRoute"}:     {schema.GroupResource{Group: "gateway.networking.k8s.io", Resource: "tcproutes"}, false},
+	{Group: "gateway.networking.k8s.io", Kind: "TLSRoute"}:     {schema.GroupResource{Group: "gateway.networking.k8s.io", Resource: "tlsroutes"}, false},
+	{Group: "gateway.networking.k8s.io", Kind: "UDPRoute"}:     {schema.GroupResource{Group: "gateway.networking.k8s.io", Resource: "udproutes"}, false},
+}
+
+var crdKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+// index indexes the objects of the manifests by resource. There is no
+// discovery without a cluster, so the resources of kinds are those of the
+// CustomResourceDefinitions of the manifests, or of builtinResources. Objects
+// of other kinds are ignored with a warning.
+func (m *manifests) index() {
+	resources := make(map[schema.GroupKind]resource, len(builtinResources))
+	for gk, r := range builtinResources {
+		resources[gk] = r
+	}
+	for _, obj := range m.objects {
+		if obj.GroupVersionKind().GroupKind() != crdKind {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+		plural, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "plural")
+		scope, _, _ := unstructured.NestedString(obj.Object, "spec", "scope")
+		resources[schema.GroupKind{Group: group, Kind: kind}] = resource{
+			GroupResource: schema.GroupResource{Group: group, Resource: plural},
+			clusterScoped: scope == "Cluster",
+		}
+	}
+
+	m.byResource = map[schema.GroupResource][]unstructured.Unstructured{}
+	m.scopes = map[schema.GroupResource]bool{}
+	m.warnings = sets.New[string]()
+	for _, r := range resources {
+		m.scopes[r.GroupResource] = r.clusterScoped
+	}
+	for _, obj := range m.objects {
+		gk := obj.GroupVersionKind().GroupKind()
+		r, ok := resources[gk]
+		if !ok {
+			if gk != crdKind {
+				nn := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+				m.warnings.Insert(fmt.Sprintf("ignoring %s %s: no CustomResourceDefinition in the manifests defines its kind", gk, nn))
+			}
+			continue
+		}
+		m.byResource[r.GroupResource] = append(m.byResource[r.GroupResource], obj)
+	}
+}
+
+// clusterScoped reports whether a resource of the manifests is
+// cluster-scoped.
+func (m *manifests) clusterScoped(gr schema.GroupResource) (bool, error) {
+	clusterScoped, ok := m.scopes[gr]
+	if !ok {
+		return false, fmt.Errorf("unknown resource %s: add its CustomResourceDefinition to the manifests", gr)
+	}
+	return clusterScoped, nil
+}
+
+// target looks up a target in the objects of the manifests. Targets missing
+// from the manifests are reported in the warnings, as they match exclusions
+// selecting targets by labels or Secret types.
+func (m *manifests) target(gr schema.GroupResource, target types.NamespacedName) (*unstructured.Unstructured, error) {
+	for i, obj := range m.byResource[gr] {
+		if obj.GetNamespace() == target.Namespace && obj.GetName() == target.Name {
+			return &m.byResource[gr][i], nil
+		}
+	}
+	m.warnings.Insert(fmt.Sprintf("%s %s is not in the manifests, so ReferencePolicies selecting labels or Secret types exclude it", gr, target))
+	return nil, nil
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+const proxyController = "system:serviceaccount:proxy-system:proxy-controller"
+
+func TestDryRun(t *testing.T) {
+	m, err := loadManifests([]string{"testdata/manifests"})
+	if err != nil {
+		t.Fatalf("loadManifests() error = %v", err)
+	}
+	s, err := m.store()
+	if err != nil {
+		t.Fatalf("store() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		group     string
+		resource  string
+		namespace string
+		target    string
+		want      bool
+		wantLink  string
+	}{
+		{name: "namespaced target", resource: "secrets", namespace: "demo", target: "edge-tls", want: true},
+		{name: "target missing from the manifests", resource: "secrets", namespace: "demo", target: "internal-tls", wantLink: store.LinkReferencePolicy},
+		{name: "cluster-scoped custom resource", group: "example.com", resource: "backendpools", target: "shared", want: true},
+		{name: "cluster-scoped built-in resource", group: "storage.k8s.io", resource: "storageclasses", target: "fast", want: true},
+		{name: "unreferenced target", group: "storage.k8s.io", resource: "storageclasses", target: "slow", wantLink: store.LinkReference},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			explanation := s.Explain(proxyController, tt.group, tt.resource, tt.namespace, tt.target)
+			if explanation.Allowed != tt.want {
+				t.Fatalf("Explain() allowed = %v, want %v: %+v", explanation.Allowed, tt.want, explanation.MissingLinks)
+			}
+			if tt.want {
+				return
+			}
+			if len(explanation.MissingLinks) != 1 || explanation.MissingLinks[0].Link != tt.wantLink {
+				t.Errorf("Explain() missing links = %+v, want a %s", explanation.MissingLinks, tt.wantLink)
+			}
+		})
+	}
+
+	wantWarnings := []string{
+		"ignoring Widget.example.com demo/unknown: no CustomResourceDefinition in the manifests defines its kind",
+		"secrets demo/internal-tls is not in the manifests, so ReferencePolicies selecting labels or Secret types exclude it",
+	}
+	if diff := cmp.Diff(wantWarnings, sets.List(m.warnings)); diff != "" {
+		t.Errorf("store() warnings mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDryRunClusterScoped(t *testing.T) {
+	m, err := loadManifests([]string{"testdata/manifests"})
+	if err != nil {
+		t.Fatalf("loadManifests() error = %v", err)
+	}
+	m.index()
+
+	tests := []struct {
+		name    string
+		gr      schema.GroupResource
+		want    bool
+		wantErr bool
+	}{
+		{name: "namespaced custom resource", gr: schema.GroupResource{Group: "example.com", Resource: "proxies"}},
+		{name: "cluster-scoped custom resource", gr: schema.GroupResource{Group: "example.com", Resource: "backendpools"}, want: true},
+		{name: "namespaced built-in resource", gr: schema.GroupResource{Resource: "secrets"}},
+		{name: "cluster-scoped built-in resource", gr: schema.GroupResource{Resource: "namespaces"}, want: true},
+		{name: "irregular plural", gr: schema.GroupResource{Group: "gateway.networking.k8s.io", Resource: "gateways"}},
+		{name: "unknown resource", gr: schema.GroupResource{Group: "example.com", Resource: "widgets"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.clusterScoped(tt.gr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("clusterScoped() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("clusterScoped() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDryRunUnknownResource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "widgets.yaml")
+	err := os.WriteFile(path, []byte(`
+apiVersion: reference.authorization.k8s.io/v1alpha1
+kind: ClusterReferenceGrant
+metadata:
+  name: proxies
+from: {group: example.com, resource: proxies}
+versions:
+- version: v1
+  references:
+  - path: "$.spec.widgetRef"
+    to: {group: example.com, resource: widgets}
+    for: widgets
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := loadManifests([]string{"testdata/manifests", path})
+	if err != nil {
+		t.Fatalf("loadManifests() error = %v", err)
+	}
+	if _, err := m.store(); err == nil {
+		t.Errorf("store() expected an error for a To resource without a CustomResourceDefinition")
+	}
+}
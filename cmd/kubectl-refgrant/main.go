@@ -0,0 +1,295 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-refgrant is a kubectl plugin inspecting the graph of the
+// referential authorizer, e.g. `kubectl refgrant who-can secrets my-secret -n ns`.
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/sets"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+type options struct {
+	server               string
+	certificateAuthority string
+	clientCertificate    string
+	clientKey            string
+	filenames            []string
+	namespace            string
+	output               string
+
+	// manifests are loaded from filenames, if set.
+	manifests *manifests
+	// local is the graph computed from manifests.
+	local *store.AuthStore
+}
+
+func main() {
+	o := &options{}
+	root := &cobra.Command{
+		Use:          "kubectl-refgrant",
+		Short:        "Inspect the graph of the referential authorizer",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(o.filenames) == 0 {
+				return nil
+			}
+			var err error
+			if o.manifests, err = loadManifests(o.filenames); err != nil {
+				return err
+			}
+			if o.local, err = o.manifests.store(); err != nil {
+				return err
+			}
+			for _, warning := range sets.List(o.manifests.warnings) {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+			}
+			return nil
+		},
+	}
+	root.PersistentFlags().StringVar(&o.server, "server", "https://localhost:8082", "Address of the debug server of the authorizer")
+	root.PersistentFlags().StringVar(&o.certificateAuthority, "certificate-authority", "", "Path to the CA bundle verifying the debug server")
+	root.PersistentFlags().StringVar(&o.clientCertificate, "client-certificate", "", "Path to the client certificate presented to the debug server")
+	root.PersistentFlags().StringVar(&o.clientKey, "client-key", "", "Path to the private key of the client certificate")
+	root.PersistentFlags().StringSliceVarP(&o.filenames, "filename", "f", nil, "Manifests or directories of manifests to compute the graph from, instead of querying the authorizer")
+	root.PersistentFlags().StringVarP(&o.namespace, "namespace", "n", "", "Namespace of the targets")
+	root.PersistentFlags().StringVarP(&o.output, "output", "o", "", "Output format. One of: json")
+
+	var user, key string
+	graph := &cobra.Command{
+		Use:   "graph",
+		Short: "Show the edges of the graph",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			view, err := o.view(user, key)
+			if err != nil {
+				return err
+			}
+			if o.output == "json" {
+				return printJSON(view)
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "KEY\tTARGET\tSUBJECTS\tSOURCES")
+			for _, kv := range view.Keys {
+				for _, edge := range kv.Edges {
+					subjects := make([]string, 0, len(edge.Subjects))
+					for _, subject := range edge.Subjects {
+						subjects = append(subjects, subject.Name)
+					}
+					sources := make([]string, 0, len(edge.Sources))
+					for _, source := range edge.Sources {
+						sources = append(sources, source.String())
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", kv.Key, edge.Target, strings.Join(subjects, ","), strings.Join(sources, ","))
+				}
+			}
+			return w.Flush()
+		},
+	}
+	graph.Flags().StringVar(&user, "user", "", "Only show edges granting access to the user")
+	graph.Flags().StringVar(&key, "key", "", "Only show edges of the from-to-for key")
+
+	whoCan := &cobra.Command{
+		Use:   "who-can RESOURCE[.GROUP] NAME",
+		Short: "Show the subjects allowed to access a target",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resource, group := splitResource(args[0])
+			trg := store.TargetResourceGroup(fmt.Sprintf("%s/%s", group, resource))
+			view, err := o.view("", "")
+			if err != nil {
+				return err
+			}
+			grants := []store.SubjectView{}
+			for _, sv := range view.Subjects {
+				matched := store.SubjectView{Subject: sv.Subject}
+				for _, grant := range sv.Grants {
+					if grant.Resource == trg && grant.Target.Name == args[1] {
+						matched.Grants = append(matched.Grants, grant)
+					}
+				}
+				if len(matched.Grants) > 0 {
+					grants = append(grants, matched)
+				}
+			}
+			if o.output == "json" {
+				return printJSON(grants)
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "KIND\tSUBJECT\tKEYS")
+			for _, sv := range grants {
+				for _, grant := range sv.Grants {
+					fmt.Fprintf(w, "%s\t%s\t%s\n", sv.Subject.Kind, sv.Subject.Name, strings.Join(grant.Keys, ","))
+				}
+			}
+			return w.Flush()
+		},
+	}
+
+	canI := &cobra.Command{
+		Use:   "can-i USER RESOURCE[.GROUP] NAME",
+		Short: "Explain whether a user is allowed to access a target",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resource, group := splitResource(args[1])
+			explanation, err := o.explain(args[0], group, resource, args[2])
+			if err != nil {
+				return err
+			}
+			if o.output == "json" {
+				if err := printJSON(explanation); err != nil {
+					return err
+				}
+			} else if explanation.Allowed {
+				fmt.Println("yes")
+				for _, chain := range explanation.Chains {
+					fmt.Printf("  %s: consumers %v, grants %v, sources %v, reference grants %v\n", chain.Key, chain.ClusterReferenceConsumers, chain.ClusterReferenceGrants, chain.Sources, chain.ReferenceGrants)
+				}
+			} else {
+				fmt.Println("no")
+				for _, missing := range explanation.MissingLinks {
+					fmt.Printf("  missing %s: %s\n", missing.Link, missing.Message)
+				}
+			}
+			if !explanation.Allowed {
+				// Like kubectl auth can-i, exit with an error when not allowed.
+				os.Exit(1)
+			}
+			return nil
+		},
+	}
+
+	root.AddCommand(graph, whoCan, canI, newAuthorizationConfigCommand(o))
+	if err := root.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// view fetches the graph, filtered by the options and by user and key.
+func (o *options) view(user, key string) (*store.GraphView, error) {
+	if o.local != nil {
+		filter := store.GraphFilter{Key: key, Namespace: o.namespace}
+		if user != "" {
+			filter.Subject = &v1a1.Subject{Kind: "User", Name: user}
+		}
+		view := o.local.View(filter)
+		return &view, nil
+	}
+	q := url.Values{}
+	if user != "" {
+		q.Set("user", user)
+	}
+	if key != "" {
+		q.Set("key", key)
+	}
+	if o.namespace != "" {
+		q.Set("namespace", o.namespace)
+	}
+	view := &store.GraphView{}
+	if err := o.get("/graph", q, view); err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// explain explains whether user is allowed to access the named target.
+func (o *options) explain(user, group, resource, name string) (*store.Explanation, error) {
+	if o.local != nil {
+		explanation := o.local.Explain(user, group, resource, o.namespace, name)
+		return &explanation, nil
+	}
+	q := url.Values{}
+	q.Set("user", user)
+	q.Set("group", group)
+	q.Set("resource", resource)
+	q.Set("namespace", o.namespace)
+	q.Set("name", name)
+	explanation := &store.Explanation{}
+	if err := o.get("/explain", q, explanation); err != nil {
+		return nil, err
+	}
+	return explanation, nil
+}
+
+func (o *options) get(path string, q url.Values, out interface{}) error {
+	httpClient, err := o.httpClient()
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Get(fmt.Sprintf("%s%s?%s", strings.TrimSuffix(o.server, "/"), path, q.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, out)
+}
+
+// httpClient returns a client authenticating to the debug server with the
+// client certificate, if any.
+func (o *options) httpClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if o.certificateAuthority != "" {
+		caBundle, err := os.ReadFile(o.certificateAuthority)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("no certificates found in %s", o.certificateAuthority)
+		}
+	}
+	if o.clientCertificate != "" {
+		cert, err := tls.LoadX509KeyPair(o.clientCertificate, o.clientKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}, nil
+}
+
+// splitResource splits "resource.group" into its resource and group.
+func splitResource(arg string) (string, string) {
+	resource, group, _ := strings.Cut(arg, ".")
+	return resource, group
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
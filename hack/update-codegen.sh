@@ -26,6 +26,6 @@ readonly VERSION=v1alpha1
 
 echo "Generating ${VERSION} register at ${APIS_PKG}/apis/${VERSION}"
 go run k8s.io/code-generator/cmd/register-gen \
-    --input-dirs "./apis/v1alpha1" \
-    --output-package "./apis/v1alpha1" \
-    --go-header-file ./hack/boilerplate/boilerplate.generatego.txt
+    --output-file zz_generated.register.go \
+    --go-header-file ./hack/boilerplate/boilerplate.generatego.txt \
+    ./apis/v1alpha1
@@ -17,51 +17,117 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
-	"time"
 
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/referencegrant-poc/cmd/controller"
+	"sigs.k8s.io/referencegrant-poc/pkg/audit"
+	"sigs.k8s.io/referencegrant-poc/pkg/config"
 	"sigs.k8s.io/referencegrant-poc/pkg/handlers"
+	"sigs.k8s.io/referencegrant-poc/pkg/metrics"
+	"sigs.k8s.io/referencegrant-poc/pkg/server"
 	"sigs.k8s.io/referencegrant-poc/pkg/store"
 )
 
-func waitForFile(filePath string) {
-	for {
-		_, err := os.Stat(filePath)
-		if err == nil {
-			fmt.Println("file exists")
-			break
-		} else if os.IsNotExist(err) {
-			// File doesn't exist yet, wait for a while before checking again
-			time.Sleep(3 * time.Second)
-		} else {
-			// Some other error occurred, print and exit
-			fmt.Println("Error:", err)
-			return
-		}
+func main() {
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	cfg, err := config.Load(fs, os.Args[1:])
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if err := run(ctrl.SetupSignalHandler(), cfg); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
 	}
 }
 
-func main() {
+// run runs the controller and the servers until ctx is done, e.g. on SIGTERM,
+// and waits for in-flight requests to drain.
+func run(ctx context.Context, cfg *config.AuthorizerConfiguration) error {
+	var auditLogger *audit.Logger
+	if cfg.Audit.Path != "" {
+		var err error
+		auditLogger, err = audit.NewForPath(cfg.Audit.Path, audit.Options{SampleRate: cfg.Audit.SampleRate, Resources: cfg.Audit.Resources})
+		if err != nil {
+			return err
+		}
+	}
+
+	kConfig, err := cfg.RESTConfig()
+	if err != nil {
+		return fmt.Errorf("could not load cluster configuration: %w", err)
+	}
+
 	authStore := store.NewAuthStore()
+	c, err := controller.New(kConfig, authStore, controller.Options{
+		Mode:                       controller.Mode(cfg.Mode),
+		LeaderElection:             cfg.LeaderElection,
+		Verbosity:                  cfg.Logging.Verbosity,
+		AuthorizationConfiguration: cfg.AuthorizationConfiguration,
+		MaxChainDepth:              cfg.MaxChainDepth,
+	})
+	if err != nil {
+		return err
+	}
 
-	http.HandleFunc("/health", handlers.HandleHealthCheck)
-	http.HandleFunc("/authorize", handlers.AuthzHandler(authStore))
-	go func() {
-		fmt.Println("Starting server on port 8081...")
-		if err := http.ListenAndServe(":8081", nil); err != nil {
-			fmt.Printf("Failed to start server: %v\n", err)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", handlers.HandleHealthCheck)
+	if cfg.Mode == config.ModeWebhook {
+		mux.HandleFunc("/authorize", handlers.AuthzHandler(authStore, handlers.AuthzOptions{
+			AuditLogger:        auditLogger,
+			ProtectedResources: cfg.ProtectedResources,
+			DecisionCacheSize:  cfg.DecisionCacheSize,
+			GenerationInReason: cfg.Audit.GenerationInReason,
+		}))
+	}
+	switch cfg.Metrics.BindAddress {
+	case "":
+		mux.Handle("/metrics", metrics.Handler())
+	case "0":
+	default:
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metrics.Handler())
+		metricsServer, err := server.New("metrics", config.ServerConfiguration{
+			BindAddress:     cfg.Metrics.BindAddress,
+			ShutdownTimeout: cfg.Server.ShutdownTimeout,
+		}, metricsMux)
+		if err != nil {
+			return err
 		}
-	}()
-
-	waitForFile(os.Getenv("KUBECONFIG"))
+		if err := c.Add(metricsServer); err != nil {
+			return err
+		}
+	}
 
-	controller.NewController(authStore)
+	if cfg.Debug.BindAddress != "" {
+		debugMux := http.NewServeMux()
+		debugMux.HandleFunc("/explain", handlers.ExplainHandler(authStore))
+		debugMux.HandleFunc("/graph", handlers.GraphHandler(authStore))
+		debugServer, err := server.New("debug", config.ServerConfiguration{
+			BindAddress:     cfg.Debug.BindAddress,
+			TLS:             cfg.Debug.TLS,
+			ShutdownTimeout: cfg.Server.ShutdownTimeout,
+		}, debugMux)
+		if err != nil {
+			return err
+		}
+		if err := c.Add(debugServer); err != nil {
+			return err
+		}
+	}
 
-	// ctx, cancel := context.WithCancel(context.Background())
-	// defer cancel()
-	// go ctrl.Start(ctx)
+	webhookServer, err := server.New("webhook", cfg.Server, mux)
+	if err != nil {
+		return err
+	}
+	if err := c.Add(webhookServer); err != nil {
+		return err
+	}
 
+	return c.Start(ctx)
 }
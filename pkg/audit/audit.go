@@ -0,0 +1,169 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit writes a structured stream of authorization decisions as JSON
+// lines, so that it can be answered after the fact why a subject could access
+// a referenced resource.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+// Record is a single audited authorization decision.
+type Record struct {
+	Time     time.Time `json:"time"`
+	User     string    `json:"user"`
+	Groups   []string  `json:"groups,omitempty"`
+	Verb     string    `json:"verb,omitempty"`
+	Target   Target    `json:"target"`
+	Purpose  string    `json:"purpose,omitempty"`
+	Decision string    `json:"decision"`
+	Reason   string    `json:"reason,omitempty"`
+	// Generation is the generation of the graph the decision was made on.
+	Generation int64 `json:"generation"`
+	// Justifications hold the from-to-for keys and From objects that
+	// justified an allowed decision.
+	Justifications []Justification `json:"justifications,omitempty"`
+}
+
+// Target is the resource a decision was made for.
+type Target struct {
+	Group     string `json:"group"`
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// Justification is a from-to-for key that granted access to a target, and the
+// From objects whose references justify it.
+type Justification struct {
+	Key     string                 `json:"key"`
+	Target  types.NamespacedName   `json:"target"`
+	Sources []types.NamespacedName `json:"sources"`
+}
+
+// Options configure which decisions are audited.
+type Options struct {
+	// SampleRate is the fraction of decisions that are written, between 0
+	// and 1. Zero writes every decision.
+	SampleRate float64
+	// Resources limits auditing to decisions for these resources, formatted
+	// as "resource" for the core group or "resource.group". When empty, all
+	// resources are audited.
+	Resources []string
+}
+
+// Logger writes Records as JSON lines. It is safe for concurrent use.
+type Logger struct {
+	mutex     sync.Mutex
+	encoder   *json.Encoder
+	rand      *rand.Rand
+	options   Options
+	resources sets.Set[string]
+}
+
+// New returns a Logger writing to w.
+func New(w io.Writer, options Options) *Logger {
+	return &Logger{
+		encoder:   json.NewEncoder(w),
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		options:   options,
+		resources: sets.New(options.Resources...),
+	}
+}
+
+// NewForPath returns a Logger writing to the file at path, or to stdout if
+// path is "-". The file is appended to if it exists.
+func NewForPath(path string, options Options) (*Logger, error) {
+	if path == "-" {
+		return New(os.Stdout, options), nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("could not open audit log: %w", err)
+	}
+	return New(f, options), nil
+}
+
+// Log records the decision made for a SubjectAccessReview, if it passes the
+// resource filter and is sampled. A nil Logger logs nothing.
+func (l *Logger) Log(sar authorizationv1.SubjectAccessReview, decision string, d store.Decision) {
+	if l == nil {
+		return
+	}
+
+	record := Record{
+		Time:       time.Now().UTC(),
+		User:       sar.Spec.User,
+		Groups:     sar.Spec.Groups,
+		Decision:   decision,
+		Reason:     sar.Status.Reason,
+		Purpose:    string(d.Purpose),
+		Generation: d.Generation,
+	}
+	if attrs := sar.Spec.ResourceAttributes; attrs != nil {
+		record.Verb = attrs.Verb
+		record.Target = Target{
+			Group:     attrs.Group,
+			Resource:  attrs.Resource,
+			Namespace: attrs.Namespace,
+			Name:      attrs.Name,
+		}
+	}
+	if !l.matches(record.Target) {
+		return
+	}
+	for _, j := range d.Justifications {
+		record.Justifications = append(record.Justifications, Justification{
+			Key:     j.Key,
+			Target:  j.Target,
+			Sources: j.Sources,
+		})
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.options.SampleRate > 0 && l.options.SampleRate < 1 && l.rand.Float64() >= l.options.SampleRate {
+		return
+	}
+	// Failing to audit shouldn't fail the authorization request.
+	_ = l.encoder.Encode(record)
+}
+
+// matches returns true if the target passes the resource filter.
+func (l *Logger) matches(target Target) bool {
+	if l.resources.Len() == 0 {
+		return true
+	}
+	resource := target.Resource
+	if target.Group != "" {
+		resource = strings.Join([]string{target.Resource, target.Group}, ".")
+	}
+	return l.resources.Has(resource)
+}
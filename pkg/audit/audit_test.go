@@ -0,0 +1,171 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+func review(group, resource string) authorizationv1.SubjectAccessReview {
+	return authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   "system:serviceaccount:demo:demo-controller",
+			Groups: []string{"system:serviceaccounts"},
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Verb:      "get",
+				Group:     group,
+				Resource:  resource,
+				Namespace: "demo",
+				Name:      "tls",
+			},
+		},
+		Status: authorizationv1.SubjectAccessReviewStatus{Reason: "granted by reference"},
+	}
+}
+
+// records decodes the JSON lines written to buf.
+func records(t *testing.T, buf *bytes.Buffer) []Record {
+	t.Helper()
+	out := []Record{}
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		record := Record{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		out = append(out, record)
+	}
+	return out
+}
+
+func TestLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New(buf, Options{})
+	l.Log(review("", "secrets"), "allowed", store.Decision{
+		Allowed:    true,
+		Purpose:    "tls-serving",
+		Generation: 3,
+		Justifications: []store.Justification{{
+			Key:     "gateway.networking.k8s.io/gateways;/secrets;tls-serving",
+			Target:  types.NamespacedName{Namespace: "demo", Name: "tls"},
+			Sources: []types.NamespacedName{{Namespace: "demo", Name: "gw"}},
+		}},
+	})
+	l.Log(review("", "secrets"), "no-opinion", store.Decision{Generation: 3})
+
+	want := []Record{
+		{
+			User:       "system:serviceaccount:demo:demo-controller",
+			Groups:     []string{"system:serviceaccounts"},
+			Verb:       "get",
+			Target:     Target{Resource: "secrets", Namespace: "demo", Name: "tls"},
+			Purpose:    "tls-serving",
+			Decision:   "allowed",
+			Reason:     "granted by reference",
+			Generation: 3,
+			Justifications: []Justification{{
+				Key:     "gateway.networking.k8s.io/gateways;/secrets;tls-serving",
+				Target:  types.NamespacedName{Namespace: "demo", Name: "tls"},
+				Sources: []types.NamespacedName{{Namespace: "demo", Name: "gw"}},
+			}},
+		},
+		{
+			User:       "system:serviceaccount:demo:demo-controller",
+			Groups:     []string{"system:serviceaccounts"},
+			Verb:       "get",
+			Target:     Target{Resource: "secrets", Namespace: "demo", Name: "tls"},
+			Decision:   "no-opinion",
+			Reason:     "granted by reference",
+			Generation: 3,
+		},
+	}
+	got := records(t, buf)
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(Record{}, "Time")); diff != "" {
+		t.Errorf("Log() mismatch (-want +got):\n%s", diff)
+	}
+	for _, record := range got {
+		if record.Time.IsZero() {
+			t.Errorf("Log() record has no time")
+		}
+	}
+}
+
+func TestLogResources(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []string
+		group     string
+		resource  string
+		want      bool
+	}{
+		{name: "no filter", resource: "secrets", want: true},
+		{name: "core resource", resources: []string{"secrets"}, resource: "secrets", want: true},
+		{name: "other core resource", resources: []string{"secrets"}, resource: "configmaps", want: false},
+		{name: "grouped resource", resources: []string{"gateways.gateway.networking.k8s.io"}, group: "gateway.networking.k8s.io", resource: "gateways", want: true},
+		{name: "resource of another group", resources: []string{"secrets"}, group: "example.com", resource: "secrets", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			New(buf, Options{Resources: tt.resources}).Log(review(tt.group, tt.resource), "allowed", store.Decision{})
+			if got := len(records(t, buf)) == 1; got != tt.want {
+				t.Errorf("Log() audited = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogSampling(t *testing.T) {
+	const decisions = 1000
+	tests := []struct {
+		name       string
+		sampleRate float64
+		min, max   int
+	}{
+		{name: "zero writes every decision", sampleRate: 0, min: decisions, max: decisions},
+		{name: "one writes every decision", sampleRate: 1, min: decisions, max: decisions},
+		{name: "fraction", sampleRate: 0.25, min: 200, max: 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			l := New(buf, Options{SampleRate: tt.sampleRate})
+			l.rand = rand.New(rand.NewSource(1))
+			for i := 0; i < decisions; i++ {
+				l.Log(review("", "secrets"), "allowed", store.Decision{})
+			}
+			if got := len(records(t, buf)); got < tt.min || got > tt.max {
+				t.Errorf("Log() wrote %d of %d decisions, want between %d and %d", got, decisions, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestLogNil(t *testing.T) {
+	var l *Logger
+	l.Log(review("", "secrets"), "allowed", store.Decision{})
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package authzconfig generates the webhook entry of the structured
+// AuthorizationConfiguration of kube-apiservers, so that the webhook is only
+// called for the group/resources targeted by ClusterReferenceGrants, and
+// merges it into their authorizer chain.
+package authzconfig
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/config"
+	"sigs.k8s.io/yaml"
+)
+
+// Defaults of the webhook entry.
+const (
+	DefaultWebhookName = "referencegrant"
+	DefaultTimeout     = 3 * time.Second
+	DefaultTTL         = 5 * time.Second
+)
+
+// The types below are the subset of the kube-apiserver AuthorizationConfiguration
+// written by Generate and read by Merge.
+
+// AuthorizationConfiguration configures the authorizers of kube-apiservers.
+type AuthorizationConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+	Authorizers     []AuthorizerConfiguration `json:"authorizers"`
+}
+
+// AuthorizerConfiguration is an authorizer of the chain.
+type AuthorizerConfiguration struct {
+	Type    string                `json:"type"`
+	Name    string                `json:"name"`
+	Webhook *WebhookConfiguration `json:"webhook,omitempty"`
+}
+
+// WebhookConfiguration configures a webhook authorizer.
+type WebhookConfiguration struct {
+	AuthorizedTTL                            metav1.Duration         `json:"authorizedTTL"`
+	UnauthorizedTTL                          metav1.Duration         `json:"unauthorizedTTL"`
+	Timeout                                  metav1.Duration         `json:"timeout"`
+	SubjectAccessReviewVersion               string                  `json:"subjectAccessReviewVersion"`
+	MatchConditionSubjectAccessReviewVersion string                  `json:"matchConditionSubjectAccessReviewVersion"`
+	FailurePolicy                            string                  `json:"failurePolicy"`
+	ConnectionInfo                           WebhookConnectionInfo   `json:"connectionInfo"`
+	MatchConditions                          []WebhookMatchCondition `json:"matchConditions"`
+}
+
+// WebhookConnectionInfo tells kube-apiservers how to reach the webhook.
+type WebhookConnectionInfo struct {
+	Type           string `json:"type"`
+	KubeConfigFile string `json:"kubeConfigFile,omitempty"`
+}
+
+// WebhookMatchCondition is a CEL expression a request must match for the
+// webhook to be called.
+type WebhookMatchCondition struct {
+	Expression string `json:"expression"`
+}
+
+// Generate returns the webhook authorizer calling the authorizer only for the
+// To resources of crgs. It is only an entry of the authorizer chain of
+// kube-apiservers, see Merge.
+func Generate(crgs []v1a1.ClusterReferenceGrant, cfg config.StructuredAuthorizationConfiguration) AuthorizerConfiguration {
+	name := cfg.WebhookName
+	if name == "" {
+		name = DefaultWebhookName
+	}
+	return AuthorizerConfiguration{
+		Type: "Webhook",
+		Name: name,
+		Webhook: &WebhookConfiguration{
+			AuthorizedTTL:                            orDefault(cfg.AuthorizedTTL, DefaultTTL),
+			UnauthorizedTTL:                          orDefault(cfg.UnauthorizedTTL, DefaultTTL),
+			Timeout:                                  orDefault(cfg.Timeout, DefaultTimeout),
+			SubjectAccessReviewVersion:               "v1",
+			MatchConditionSubjectAccessReviewVersion: "v1",
+			// The webhook never denies, so it is safe to skip it.
+			FailurePolicy: "NoOpinion",
+			ConnectionInfo: WebhookConnectionInfo{
+				Type:           "KubeConfigFile",
+				KubeConfigFile: cfg.KubeConfigFile,
+			},
+			MatchConditions: MatchConditions(crgs),
+		},
+	}
+}
+
+// MatchConditions returns the CEL conditions matching requests for the To
+// resources of crgs. Like the graph, only the first version of each
+// ClusterReferenceGrant is considered. Without any, no request matches.
+func MatchConditions(crgs []v1a1.ClusterReferenceGrant) []WebhookMatchCondition {
+	targets := sets.New[v1a1.GroupResource]()
+	for _, crg := range crgs {
+		if len(crg.Versions) == 0 {
+			continue
+		}
+		for _, rp := range crg.Versions[0].References {
+			targets.Insert(rp.To)
+		}
+	}
+	if targets.Len() == 0 {
+		return []WebhookMatchCondition{{Expression: "false"}}
+	}
+
+	sorted := targets.UnsortedList()
+	sort.Slice(sorted, func(i, j int) bool { return lessGroupResource(sorted[i], sorted[j]) })
+	matches := make([]string, 0, len(sorted))
+	for _, gr := range sorted {
+		matches = append(matches, fmt.Sprintf("(request.resourceAttributes.group == %s && request.resourceAttributes.resource == %s)",
+			strconv.Quote(gr.Group), strconv.Quote(gr.Resource)))
+	}
+	return []WebhookMatchCondition{
+		{Expression: "has(request.resourceAttributes)"},
+		{Expression: strings.Join(matches, " || ")},
+	}
+}
+
+// Merge returns the AuthorizationConfiguration in data with webhook in place
+// of the authorizer of the same name. Every other authorizer is kept as is. A
+// new webhook is inserted before the RBAC authorizer, or last without one.
+func Merge(data []byte, webhook AuthorizerConfiguration) ([]byte, error) {
+	ac := AuthorizationConfiguration{}
+	if err := yaml.Unmarshal(data, &ac); err != nil {
+		return nil, err
+	}
+	if ac.Kind != "AuthorizationConfiguration" {
+		return nil, fmt.Errorf("not an AuthorizationConfiguration: kind %q", ac.Kind)
+	}
+
+	// Other authorizers may have fields unknown to AuthorizerConfiguration, so
+	// they are merged untyped.
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	entryData, err := yaml.Marshal(webhook)
+	if err != nil {
+		return nil, err
+	}
+	entry := map[string]interface{}{}
+	if err := yaml.Unmarshal(entryData, &entry); err != nil {
+		return nil, err
+	}
+
+	authorizers, _ := doc["authorizers"].([]interface{})
+	at := len(authorizers)
+	for i, a := range ac.Authorizers {
+		if a.Name == webhook.Name {
+			authorizers[i] = entry
+			at = -1
+			break
+		}
+		if a.Type == "RBAC" && i < at {
+			at = i
+		}
+	}
+	if at >= 0 {
+		authorizers = append(authorizers[:at], append([]interface{}{entry}, authorizers[at:]...)...)
+	}
+	doc["authorizers"] = authorizers
+	return yaml.Marshal(doc)
+}
+
+// WriteFile merges webhook into the AuthorizationConfiguration at path, and
+// writes it unless it is unchanged. The file must exist, since it also holds
+// the rest of the authorizer chain. It is replaced atomically, so
+// kube-apiservers never load a partial file.
+func WriteFile(path string, webhook AuthorizerConfiguration) (bool, error) {
+	current, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	data, err := Merge(current, webhook)
+	if err != nil {
+		return false, fmt.Errorf("could not merge the webhook into %s: %w", path, err)
+	}
+	if bytes.Equal(current, data) {
+		return false, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func orDefault(d metav1.Duration, def time.Duration) metav1.Duration {
+	if d.Duration == 0 {
+		return metav1.Duration{Duration: def}
+	}
+	return d
+}
+
+func lessGroupResource(a, b v1a1.GroupResource) bool {
+	if a.Group != b.Group {
+		return a.Group < b.Group
+	}
+	return a.Resource < b.Resource
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authzconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/config"
+	"sigs.k8s.io/yaml"
+)
+
+func crg(to ...v1a1.GroupResource) v1a1.ClusterReferenceGrant {
+	refs := []v1a1.ReferencePath{}
+	for _, gr := range to {
+		refs = append(refs, v1a1.ReferencePath{Path: ".spec.ref", To: gr, For: "test"})
+	}
+	return v1a1.ClusterReferenceGrant{
+		From:     v1a1.GroupResource{Group: "gateway.networking.k8s.io", Resource: "gateways"},
+		Versions: []v1a1.VersionedReferencePaths{{Version: "v1", References: refs}},
+	}
+}
+
+func TestMatchConditions(t *testing.T) {
+	secrets := v1a1.GroupResource{Group: "", Resource: "secrets"}
+	backends := v1a1.GroupResource{Group: "example.com", Resource: "backends"}
+
+	tests := []struct {
+		name string
+		crgs []v1a1.ClusterReferenceGrant
+		want []WebhookMatchCondition
+	}{
+		{
+			name: "no grants",
+			want: []WebhookMatchCondition{{Expression: "false"}},
+		},
+		{
+			name: "one resource",
+			crgs: []v1a1.ClusterReferenceGrant{crg(secrets)},
+			want: []WebhookMatchCondition{
+				{Expression: "has(request.resourceAttributes)"},
+				{Expression: `(request.resourceAttributes.group == "" && request.resourceAttributes.resource == "secrets")`},
+			},
+		},
+		{
+			name: "only the first version",
+			crgs: []v1a1.ClusterReferenceGrant{{
+				Versions: []v1a1.VersionedReferencePaths{
+					{Version: "v1", References: []v1a1.ReferencePath{{Path: ".spec.ref", To: secrets, For: "test"}}},
+					{Version: "v1beta1", References: []v1a1.ReferencePath{{Path: ".spec.ref", To: backends, For: "test"}}},
+				},
+			}, {}},
+			want: []WebhookMatchCondition{
+				{Expression: "has(request.resourceAttributes)"},
+				{Expression: `(request.resourceAttributes.group == "" && request.resourceAttributes.resource == "secrets")`},
+			},
+		},
+		{
+			name: "deduplicated and sorted resources",
+			crgs: []v1a1.ClusterReferenceGrant{crg(secrets, backends), crg(secrets)},
+			want: []WebhookMatchCondition{
+				{Expression: "has(request.resourceAttributes)"},
+				{Expression: `(request.resourceAttributes.group == "" && request.resourceAttributes.resource == "secrets") || ` +
+					`(request.resourceAttributes.group == "example.com" && request.resourceAttributes.resource == "backends")`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, MatchConditions(tt.crgs)); diff != "" {
+				t.Errorf("MatchConditions() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+const chain = `apiVersion: apiserver.config.k8s.io/v1beta1
+kind: AuthorizationConfiguration
+authorizers:
+- type: Node
+  name: node
+- type: Webhook
+  name: other
+  webhook:
+    unknownField: kept
+- type: RBAC
+  name: rbac
+`
+
+// names returns the names of the authorizers in data.
+func names(t *testing.T, data []byte) []string {
+	t.Helper()
+	ac := AuthorizationConfiguration{}
+	if err := yaml.Unmarshal(data, &ac); err != nil {
+		t.Fatalf("invalid AuthorizationConfiguration: %v", err)
+	}
+	out := []string{}
+	for _, a := range ac.Authorizers {
+		out = append(out, a.Name)
+	}
+	return out
+}
+
+func TestMerge(t *testing.T) {
+	webhook := Generate([]v1a1.ClusterReferenceGrant{crg(v1a1.GroupResource{Resource: "secrets"})}, config.StructuredAuthorizationConfiguration{})
+	merged, err := Merge([]byte(chain), webhook)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "inserted before RBAC", data: chain, want: []string{"node", "other", DefaultWebhookName, "rbac"}},
+		{name: "replaced", data: string(merged), want: []string{"node", "other", DefaultWebhookName, "rbac"}},
+		{
+			name: "appended without RBAC",
+			data: "apiVersion: apiserver.config.k8s.io/v1\nkind: AuthorizationConfiguration\nauthorizers:\n- {type: Node, name: node}\n",
+			want: []string{"node", DefaultWebhookName},
+		},
+		{name: "not an AuthorizationConfiguration", data: "apiVersion: v1\nkind: ConfigMap\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Merge([]byte(tt.data), webhook)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Merge() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, names(t, got)); diff != "" {
+				t.Errorf("Merge() authorizers mismatch (-want +got):\n%s", diff)
+			}
+			if strings.Contains(tt.data, "unknownField") && !strings.Contains(string(got), "unknownField: kept") {
+				t.Errorf("Merge() dropped the fields of other authorizers:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "authorization-config.yaml")
+	cfg := config.StructuredAuthorizationConfiguration{Path: path, KubeConfigFile: "/files/webhook.conf"}
+	secrets := crg(v1a1.GroupResource{Resource: "secrets"})
+
+	if _, err := WriteFile(path, Generate(nil, cfg)); err == nil {
+		t.Errorf("WriteFile() expected an error without a file")
+	}
+	if err := os.WriteFile(path, []byte(chain), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name        string
+		crgs        []v1a1.ClusterReferenceGrant
+		wantWritten bool
+	}{
+		{name: "new webhook", crgs: []v1a1.ClusterReferenceGrant{secrets}, wantWritten: true},
+		{name: "unchanged", crgs: []v1a1.ClusterReferenceGrant{secrets}, wantWritten: false},
+		{name: "grant removed", wantWritten: true},
+	}
+	for _, step := range steps {
+		want := Generate(step.crgs, cfg)
+		written, err := WriteFile(path, want)
+		if err != nil {
+			t.Fatalf("%s: WriteFile() error = %v", step.name, err)
+		}
+		if written != step.wantWritten {
+			t.Errorf("%s: WriteFile() = %v, want %v", step.name, written, step.wantWritten)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := AuthorizationConfiguration{}
+		if err := yaml.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%s: failed to read back the file: %v", step.name, err)
+		}
+		if diff := cmp.Diff(want, got.Authorizers[2]); diff != "" {
+			t.Errorf("%s: webhook mismatch (-want +got):\n%s", step.name, diff)
+		}
+		if diff := cmp.Diff([]string{"node", "other", DefaultWebhookName, "rbac"}, names(t, data)); diff != "" {
+			t.Errorf("%s: authorizers mismatch (-want +got):\n%s", step.name, diff)
+		}
+	}
+
+	// Only the configuration is left in the directory.
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the configuration file, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+)
+
+// Default returns the default configuration.
+func Default() *AuthorizerConfiguration {
+	return &AuthorizerConfiguration{
+		TypeMeta:   metav1.TypeMeta{APIVersion: GroupVersion.String(), Kind: Kind},
+		Kubeconfig: os.Getenv("KUBECONFIG"),
+		Mode:       ModeWebhook,
+		Server: ServerConfiguration{
+			BindAddress:     ":8081",
+			ShutdownTimeout: metav1.Duration{Duration: 15 * time.Second},
+		},
+		DecisionCacheSize: 4096,
+		MaxChainDepth:     3,
+		LeaderElection: LeaderElectionConfiguration{
+			ResourceName:  "referencegrant-poc",
+			LeaseDuration: metav1.Duration{Duration: 15 * time.Second},
+			RenewDeadline: metav1.Duration{Duration: 10 * time.Second},
+			RetryPeriod:   metav1.Duration{Duration: 2 * time.Second},
+		},
+	}
+}
+
+// Load returns the configuration of the command line args. The
+// configuration file given by --config, if any, is loaded on top of the
+// defaults, and flags set in args override it.
+func Load(fs *flag.FlagSet, args []string) (*AuthorizerConfiguration, error) {
+	cfg := Default()
+	var path string
+	fs.StringVar(&path, "config", "", "Path to an AuthorizerConfiguration file. Flags override its values.")
+	addFlags(fs, cfg)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if path != "" {
+		file, err := loadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		*cfg = *file
+		// Parse again so that flags override the file.
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile loads a configuration file on top of the defaults.
+func loadFile(path string) (*AuthorizerConfiguration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := Default()
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	if cfg.APIVersion != GroupVersion.String() || cfg.Kind != Kind {
+		return nil, fmt.Errorf("%s is a %s %s, expected a %s %s", path, cfg.APIVersion, cfg.Kind, GroupVersion, Kind)
+	}
+	return cfg, nil
+}
+
+func addFlags(fs *flag.FlagSet, cfg *AuthorizerConfiguration) {
+	fs.StringVar(&cfg.Kubeconfig, "kubeconfig", cfg.Kubeconfig, "Path to the kubeconfig of the cluster to watch. Defaults to $KUBECONFIG. The in-cluster configuration is used when empty.")
+	fs.StringVar(&cfg.Mode, "mode", cfg.Mode, fmt.Sprintf("How the reference graph is enforced, one of %q or %q.", ModeWebhook, ModeRBAC))
+	fs.StringVar(&cfg.Server.BindAddress, "bind-address", cfg.Server.BindAddress, "Address the authorization webhook listens on.")
+	fs.DurationVar(&cfg.Server.ShutdownTimeout.Duration, "shutdown-timeout", cfg.Server.ShutdownTimeout.Duration, "How long in-flight requests are drained for on shutdown.")
+	fs.Func("tls-cert-file", "Path to the serving certificate. Serves plain HTTP when unset.", func(v string) error {
+		tlsConfig(cfg).CertFile = v
+		return nil
+	})
+	fs.Func("tls-private-key-file", "Path to the private key of the serving certificate.", func(v string) error {
+		tlsConfig(cfg).KeyFile = v
+		return nil
+	})
+	fs.Func("client-ca-file", "Path to a CA bundle clients must present a certificate signed by.", func(v string) error {
+		tlsConfig(cfg).ClientCAFile = v
+		return nil
+	})
+	fs.StringVar(&cfg.Debug.BindAddress, "debug-bind-address", cfg.Debug.BindAddress, "Address the debug endpoints are served on. Disabled when empty.")
+	fs.Func("debug-tls-cert-file", "Path to the serving certificate of the debug endpoints.", func(v string) error {
+		debugTLSConfig(cfg).CertFile = v
+		return nil
+	})
+	fs.Func("debug-tls-private-key-file", "Path to the private key of the serving certificate of the debug endpoints.", func(v string) error {
+		debugTLSConfig(cfg).KeyFile = v
+		return nil
+	})
+	fs.Func("debug-client-ca-file", "Path to a CA bundle clients of the debug endpoints must present a certificate signed by.", func(v string) error {
+		debugTLSConfig(cfg).ClientCAFile = v
+		return nil
+	})
+	fs.Var(stringSlice{&cfg.ProtectedResources}, "protected-resources", "Comma separated list of resources the authorization webhook has an opinion on, formatted as \"resource\" or \"resource.group\". Empty looks up all resources.")
+	fs.StringVar(&cfg.Metrics.BindAddress, "metrics-bind-address", cfg.Metrics.BindAddress, "Address metrics are served on. Served on the webhook server when empty, disabled when \"0\".")
+	fs.BoolVar(&cfg.LeaderElection.LeaderElect, "leader-elect", cfg.LeaderElection.LeaderElect, "Enable leader election between replicas.")
+	fs.StringVar(&cfg.LeaderElection.ResourceNamespace, "leader-election-namespace", cfg.LeaderElection.ResourceNamespace, "Namespace of the leader election lease.")
+	fs.StringVar(&cfg.Audit.Path, "audit-log-path", cfg.Audit.Path, "If set, authorization decisions are written as JSON lines to this file, or to stdout if \"-\".")
+	fs.Float64Var(&cfg.Audit.SampleRate, "audit-sample-rate", cfg.Audit.SampleRate, "Fraction of authorization decisions written to the audit log, between 0 and 1. Zero writes every decision.")
+	fs.Var(stringSlice{&cfg.Audit.Resources}, "audit-resources", "Comma separated list of resources to audit decisions for, formatted as \"resource\" or \"resource.group\". Empty audits all resources.")
+	fs.Func("authorization-config-path", "If set, the webhook entry of the kube-apiserver AuthorizationConfiguration in this existing file is kept up to date.", func(v string) error {
+		authorizationConfiguration(cfg).Path = v
+		return nil
+	})
+	fs.Func("authorization-webhook-kubeconfig", "Path, on kube-apiservers, to the kubeconfig used to call the webhook, written to the AuthorizationConfiguration.", func(v string) error {
+		authorizationConfiguration(cfg).KubeConfigFile = v
+		return nil
+	})
+	fs.IntVar(&cfg.DecisionCacheSize, "decision-cache-size", cfg.DecisionCacheSize, "Number of authorization decisions cached until the graph changes. Zero disables the cache.")
+	fs.IntVar(&cfg.MaxChainDepth, "max-chain-depth", cfg.MaxChainDepth, "Number of from-to-for keys a chain of references can span. One disables chaining.")
+	fs.BoolVar(&cfg.Audit.GenerationInReason, "audit-generation-in-reason", cfg.Audit.GenerationInReason, "Add the generation of the graph to the reason of decisions, for the audit logs of kube-apiservers.")
+	fs.IntVar(&cfg.Logging.Verbosity, "v", cfg.Logging.Verbosity, "Verbosity of the logs.")
+}
+
+func tlsConfig(cfg *AuthorizerConfiguration) *TLSConfiguration {
+	if cfg.Server.TLS == nil {
+		cfg.Server.TLS = &TLSConfiguration{}
+	}
+	return cfg.Server.TLS
+}
+
+func debugTLSConfig(cfg *AuthorizerConfiguration) *TLSConfiguration {
+	if cfg.Debug.TLS == nil {
+		cfg.Debug.TLS = &TLSConfiguration{}
+	}
+	return cfg.Debug.TLS
+}
+
+func authorizationConfiguration(cfg *AuthorizerConfiguration) *StructuredAuthorizationConfiguration {
+	if cfg.AuthorizationConfiguration == nil {
+		cfg.AuthorizationConfiguration = &StructuredAuthorizationConfiguration{}
+	}
+	return cfg.AuthorizationConfiguration
+}
+
+// stringSlice is a comma separated list flag that replaces its value.
+type stringSlice struct {
+	values *[]string
+}
+
+func (s stringSlice) String() string {
+	if s.values == nil {
+		return ""
+	}
+	return strings.Join(*s.values, ",")
+}
+
+func (s stringSlice) Set(v string) error {
+	*s.values = nil
+	if v != "" {
+		*s.values = strings.Split(v, ",")
+	}
+	return nil
+}
+
+// Validate returns an error if the configuration is invalid.
+func (c *AuthorizerConfiguration) Validate() error {
+	var errs []error
+	switch c.Mode {
+	case ModeWebhook, ModeRBAC:
+	default:
+		errs = append(errs, fmt.Errorf("unknown mode %q", c.Mode))
+	}
+	if c.Server.BindAddress == "" {
+		errs = append(errs, errors.New("server.bindAddress is required"))
+	}
+	if c.Server.TLS != nil && (c.Server.TLS.CertFile == "" || c.Server.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("server.tls requires both a certFile and a keyFile"))
+	}
+	if tls := c.Debug.TLS; c.Debug.BindAddress != "" && (tls == nil || tls.CertFile == "" || tls.KeyFile == "" || tls.ClientCAFile == "") {
+		errs = append(errs, errors.New("debug requires a tls certFile, keyFile and clientCAFile"))
+	}
+	if c.DecisionCacheSize < 0 {
+		errs = append(errs, fmt.Errorf("decisionCacheSize %d is negative", c.DecisionCacheSize))
+	}
+	if c.MaxChainDepth < 1 {
+		errs = append(errs, fmt.Errorf("maxChainDepth %d is less than 1", c.MaxChainDepth))
+	}
+	if c.Audit.SampleRate < 0 || c.Audit.SampleRate > 1 {
+		errs = append(errs, fmt.Errorf("audit.sampleRate %v is not between 0 and 1", c.Audit.SampleRate))
+	}
+	if ac := c.AuthorizationConfiguration; ac != nil && (ac.Path == "" || ac.KubeConfigFile == "") {
+		errs = append(errs, errors.New("authorizationConfiguration requires both a path and a kubeConfigFile"))
+	}
+	if c.LeaderElection.LeaderElect && c.LeaderElection.ResourceName == "" {
+		errs = append(errs, errors.New("leaderElection.resourceName is required"))
+	}
+	return errors.Join(errs...)
+}
+
+// RESTConfig returns the configuration of the cluster to watch, from the
+// kubeconfig if set or else from the in-cluster configuration.
+func (c *AuthorizerConfiguration) RESTConfig() (*rest.Config, error) {
+	if c.Kubeconfig == "" {
+		return rest.InClusterConfig()
+	}
+	return clientcmd.BuildConfigFromFlags("", c.Kubeconfig)
+}
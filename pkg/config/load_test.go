@@ -0,0 +1,171 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoad(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	valid := write("valid.yaml", `
+apiVersion: config.reference.authorization.k8s.io/v1alpha1
+kind: AuthorizerConfiguration
+mode: rbac
+server:
+  bindAddress: ":9443"
+protectedResources: [secrets]
+`)
+	wrongKind := write("wrong-kind.yaml", `
+apiVersion: config.reference.authorization.k8s.io/v1alpha1
+kind: Other
+`)
+	unknownField := write("unknown-field.yaml", `
+apiVersion: config.reference.authorization.k8s.io/v1alpha1
+kind: AuthorizerConfiguration
+unknown: true
+`)
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    func(*AuthorizerConfiguration)
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: func(*AuthorizerConfiguration) {},
+		},
+		{
+			name: "flags",
+			args: []string{"--mode=rbac", "--protected-resources=secrets,configmaps", "--tls-cert-file=tls.crt", "--tls-private-key-file=tls.key"},
+			want: func(cfg *AuthorizerConfiguration) {
+				cfg.Mode = ModeRBAC
+				cfg.ProtectedResources = []string{"secrets", "configmaps"}
+				cfg.Server.TLS = &TLSConfiguration{CertFile: "tls.crt", KeyFile: "tls.key"}
+			},
+		},
+		{
+			name: "file",
+			args: []string{"--config", valid},
+			want: func(cfg *AuthorizerConfiguration) {
+				cfg.Mode = ModeRBAC
+				cfg.Server.BindAddress = ":9443"
+				cfg.ProtectedResources = []string{"secrets"}
+			},
+		},
+		{
+			name: "flags override the file",
+			args: []string{"--config", valid, "--mode=webhook", "--protected-resources="},
+			want: func(cfg *AuthorizerConfiguration) {
+				cfg.Server.BindAddress = ":9443"
+			},
+		},
+		{
+			name:    "wrong kind",
+			args:    []string{"--config", wrongKind},
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			args:    []string{"--config", unknownField},
+			wantErr: true,
+		},
+		{
+			name:    "invalid mode",
+			args:    []string{"--mode=other"},
+			wantErr: true,
+		},
+		{
+			name: "authorization configuration",
+			args: []string{"--authorization-config-path=/etc/kubernetes/authz.yaml", "--authorization-webhook-kubeconfig=/etc/kubernetes/webhook.conf"},
+			want: func(cfg *AuthorizerConfiguration) {
+				cfg.AuthorizationConfiguration = &StructuredAuthorizationConfiguration{
+					Path:           "/etc/kubernetes/authz.yaml",
+					KubeConfigFile: "/etc/kubernetes/webhook.conf",
+				}
+			},
+		},
+		{
+			name:    "authorization configuration without a kubeconfig",
+			args:    []string{"--authorization-config-path=/etc/kubernetes/authz.yaml"},
+			wantErr: true,
+		},
+		{
+			name:    "max chain depth below 1",
+			args:    []string{"--max-chain-depth=0"},
+			wantErr: true,
+		},
+		{
+			name: "debug server",
+			args: []string{"--debug-bind-address=:8082", "--debug-tls-cert-file=tls.crt", "--debug-tls-private-key-file=tls.key", "--debug-client-ca-file=ca.crt"},
+			want: func(cfg *AuthorizerConfiguration) {
+				cfg.Debug = DebugConfiguration{
+					BindAddress: ":8082",
+					TLS:         &TLSConfiguration{CertFile: "tls.crt", KeyFile: "tls.key", ClientCAFile: "ca.crt"},
+				}
+			},
+		},
+		{
+			name:    "debug server without TLS",
+			args:    []string{"--debug-bind-address=:8082"},
+			wantErr: true,
+		},
+		{
+			name:    "debug server without client authentication",
+			args:    []string{"--debug-bind-address=:8082", "--debug-tls-cert-file=tls.crt", "--debug-tls-private-key-file=tls.key"},
+			wantErr: true,
+		},
+		{
+			name:    "TLS without a key",
+			args:    []string{"--tls-cert-file=tls.crt"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Load() expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			want := Default()
+			tt.want(want)
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("Load() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
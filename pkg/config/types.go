@@ -0,0 +1,202 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package config holds the versioned configuration file of the authorizer,
+// and the flags overriding it.
+package config
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GroupVersion is the group and version of the configuration file.
+var GroupVersion = schema.GroupVersion{Group: "config.reference.authorization.k8s.io", Version: "v1alpha1"}
+
+// Kind is the kind of the configuration file.
+const Kind = "AuthorizerConfiguration"
+
+// Decision modes.
+const (
+	// ModeWebhook serves the graph to kube-apiservers through the
+	// authorization webhook.
+	ModeWebhook = "webhook"
+	// ModeRBAC materializes the graph as Roles and RoleBindings.
+	ModeRBAC = "rbac"
+)
+
+// AuthorizerConfiguration configures the authorizer.
+type AuthorizerConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Kubeconfig is the path to the kubeconfig of the cluster to watch.
+	// Defaults to $KUBECONFIG. The in-cluster configuration is used when
+	// empty.
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+
+	// Mode is how the reference graph is enforced, "webhook" or "rbac".
+	// Defaults to "webhook".
+	Mode string `json:"mode,omitempty"`
+
+	// Server configures the server of the authorization webhook.
+	Server ServerConfiguration `json:"server,omitempty"`
+
+	// Debug configures the server of the debug endpoints, /explain and
+	// /graph, which disclose the reference graph. It is disabled by default.
+	Debug DebugConfiguration `json:"debug,omitempty"`
+
+	// ProtectedResources limits the authorization webhook to these
+	// resources, formatted as "resource" for the core group or
+	// "resource.group". The webhook has no opinion on other resources. When
+	// empty, all resources are looked up in the graph.
+	ProtectedResources []string `json:"protectedResources,omitempty"`
+
+	// DecisionCacheSize is the number of authorization decisions cached
+	// until the graph changes. Defaults to 4096. Zero disables the cache.
+	DecisionCacheSize int `json:"decisionCacheSize"`
+
+	// MaxChainDepth is the number of from-to-for keys a chain of references
+	// can span, e.g. 2 for Gateways referencing ListenerSets referencing
+	// Secrets. Defaults to 3. One disables chaining.
+	MaxChainDepth int `json:"maxChainDepth,omitempty"`
+
+	// Metrics configures where metrics are served.
+	Metrics MetricsConfiguration `json:"metrics,omitempty"`
+
+	// LeaderElection configures leader election between replicas.
+	LeaderElection LeaderElectionConfiguration `json:"leaderElection,omitempty"`
+
+	// Audit configures the audit log of authorization decisions.
+	Audit AuditConfiguration `json:"audit,omitempty"`
+
+	// Logging configures the logger.
+	Logging LoggingConfiguration `json:"logging,omitempty"`
+
+	// AuthorizationConfiguration, when set, keeps a structured
+	// authorization configuration file of kube-apiservers up to date as
+	// ClusterReferenceGrants change.
+	AuthorizationConfiguration *StructuredAuthorizationConfiguration `json:"authorizationConfiguration,omitempty"`
+}
+
+// ServerConfiguration configures the server of the authorization webhook.
+type ServerConfiguration struct {
+	// BindAddress is the address the server listens on. Defaults to ":8081".
+	BindAddress string `json:"bindAddress,omitempty"`
+	// TLS configures serving over HTTPS. The server serves plain HTTP when
+	// unset.
+	TLS *TLSConfiguration `json:"tls,omitempty"`
+	// ShutdownTimeout is how long in-flight requests are drained for on
+	// shutdown. Defaults to 15s.
+	ShutdownTimeout metav1.Duration `json:"shutdownTimeout,omitempty"`
+}
+
+// TLSConfiguration configures serving over HTTPS.
+type TLSConfiguration struct {
+	// CertFile is the path to the serving certificate.
+	CertFile string `json:"certFile"`
+	// KeyFile is the path to the private key of the serving certificate.
+	KeyFile string `json:"keyFile"`
+	// ClientCAFile is the path to a CA bundle. When set, clients must
+	// present a certificate signed by one of its CAs.
+	ClientCAFile string `json:"clientCAFile,omitempty"`
+}
+
+// DebugConfiguration configures the server of the debug endpoints.
+type DebugConfiguration struct {
+	// BindAddress is the address the debug endpoints are served on. They
+	// are disabled when empty.
+	BindAddress string `json:"bindAddress,omitempty"`
+	// TLS configures serving over HTTPS. It is required, with a
+	// clientCAFile, so that only authenticated clients read the graph.
+	TLS *TLSConfiguration `json:"tls,omitempty"`
+}
+
+// MetricsConfiguration configures where metrics are served.
+type MetricsConfiguration struct {
+	// BindAddress is the address metrics are served on. Metrics are served
+	// on the server of the authorization webhook when empty, and disabled
+	// when "0".
+	BindAddress string `json:"bindAddress,omitempty"`
+}
+
+// LeaderElectionConfiguration configures leader election between replicas.
+type LeaderElectionConfiguration struct {
+	// LeaderElect enables leader election, so that only one replica does
+	// side-effecting work such as RBAC materialization and status writes.
+	// Every replica builds and serves the graph.
+	LeaderElect bool `json:"leaderElect,omitempty"`
+	// ResourceNamespace is the namespace of the lease. Defaults to the
+	// namespace of the pod when running in a cluster.
+	ResourceNamespace string `json:"resourceNamespace,omitempty"`
+	// ResourceName is the name of the lease. Defaults to
+	// "referencegrant-poc".
+	ResourceName string `json:"resourceName,omitempty"`
+	// LeaseDuration is how long non-leaders wait before trying to acquire
+	// leadership. Defaults to 15s.
+	LeaseDuration metav1.Duration `json:"leaseDuration,omitempty"`
+	// RenewDeadline is how long the leader tries to renew leadership before
+	// giving it up. Defaults to 10s.
+	RenewDeadline metav1.Duration `json:"renewDeadline,omitempty"`
+	// RetryPeriod is how long to wait between tries of actions. Defaults to
+	// 2s.
+	RetryPeriod metav1.Duration `json:"retryPeriod,omitempty"`
+}
+
+// AuditConfiguration configures the audit log of authorization decisions.
+type AuditConfiguration struct {
+	// Path is the file decisions are written to as JSON lines, or "-" for
+	// stdout. Decisions aren't audited when empty.
+	Path string `json:"path,omitempty"`
+	// SampleRate is the fraction of decisions written, between 0 and 1.
+	// Zero writes every decision.
+	SampleRate float64 `json:"sampleRate,omitempty"`
+	// Resources limits auditing to these resources, formatted as "resource"
+	// or "resource.group". When empty, all resources are audited.
+	Resources []string `json:"resources,omitempty"`
+	// GenerationInReason adds the generation of the graph to the reason of
+	// decisions, so that the audit logs of kube-apiservers show which graph
+	// a decision was made on.
+	GenerationInReason bool `json:"generationInReason,omitempty"`
+}
+
+// LoggingConfiguration configures the logger.
+type LoggingConfiguration struct {
+	// Verbosity is the verbosity of the logs.
+	Verbosity int `json:"verbosity,omitempty"`
+}
+
+// StructuredAuthorizationConfiguration configures the webhook entry of a
+// kube-apiserver AuthorizationConfiguration.
+type StructuredAuthorizationConfiguration struct {
+	// Path is the existing AuthorizationConfiguration file the webhook entry
+	// is merged into. Its other authorizers are kept.
+	Path string `json:"path"`
+	// WebhookName is the name of the webhook authorizer. Defaults to
+	// "referencegrant".
+	WebhookName string `json:"webhookName,omitempty"`
+	// KubeConfigFile is the path, on the kube-apiserver, to the kubeconfig
+	// used to call the webhook.
+	KubeConfigFile string `json:"kubeConfigFile"`
+	// Timeout is how long kube-apiservers wait for the webhook. Defaults to
+	// 3s.
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+	// AuthorizedTTL is how long kube-apiservers cache allowed decisions.
+	// Defaults to 5s, so that revocations are effective quickly.
+	AuthorizedTTL metav1.Duration `json:"authorizedTTL,omitempty"`
+	// UnauthorizedTTL is how long kube-apiservers cache other decisions.
+	// Defaults to 5s.
+	UnauthorizedTTL metav1.Duration `json:"unauthorizedTTL,omitempty"`
+}
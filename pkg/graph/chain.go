@@ -0,0 +1,176 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+// DefaultMaxChainDepth is the default number of from-to-for keys a chain of
+// references can span.
+const DefaultMaxChainDepth = 3
+
+// ChainedKeys returns keys along with every from-to-for key chained to them,
+// upstream or downstream, through the keys defined by crgs. A key is chained
+// to the keys whose From resource is its To resource. Keys that are no longer
+// defined are still chained by their resources, so that revocations cascade.
+func ChainedKeys(crgs []v1a1.ClusterReferenceGrant, keys sets.Set[string]) sets.Set[string] {
+	defined := make(sets.Set[string])
+	for _, crg := range crgs {
+		defined = defined.Union(ApplicableKeys(crgs, fmt.Sprintf("%s/%s", crg.From.Group, crg.From.Resource)))
+	}
+	chained := keys.Clone()
+	queue := sets.List(keys)
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+		for _, other := range sets.List(defined) {
+			if chained.Has(other) {
+				continue
+			}
+			if keyTo(key) == keyFrom(other) || keyTo(other) == keyFrom(key) {
+				chained.Insert(other)
+				queue = append(queue, other)
+			}
+		}
+	}
+	return chained
+}
+
+// Chain authorizes the subjects of the targets of every key for what those
+// targets reference through downstream keys, whose From resource is the To
+// resource of the key, across at most maxDepth keys. Only the subjects
+// consuming a key directly are chained, so a chain ends as soon as one of its
+// references is removed. A chain never goes through a key twice, so cycles
+// end too. Chained subjects are authorized for no subresource.
+func Chain(graphs []KeyGraph, maxDepth int) []KeyGraph {
+	c := chainer{
+		maxDepth: maxDepth,
+		direct:   make(map[string]map[types.NamespacedName]store.Edge, len(graphs)),
+		graphs:   make(map[string]KeyGraph, len(graphs)),
+	}
+	for _, kg := range graphs {
+		c.graphs[kg.Key] = kg
+		c.direct[kg.Key] = make(map[types.NamespacedName]store.Edge, len(kg.Edges))
+		for _, edge := range kg.Edges {
+			c.direct[kg.Key][edge.Target] = edge
+		}
+	}
+
+	chained := make([]KeyGraph, 0, len(graphs))
+	for _, kg := range graphs {
+		kg.Provenance.Consumers = copyConsumers(kg.Provenance.Consumers)
+		edges := make([]store.Edge, 0, len(kg.Edges))
+		for _, edge := range kg.Edges {
+			subjects := sets.New(edge.Subjects...)
+			inherited := map[v1a1.Subject]sets.Set[string]{}
+			c.inherit(kg.Key, edge.Sources, sets.New(kg.Key), inherited)
+			edge.Subjects = append([]v1a1.Subject{}, edge.Subjects...)
+			for _, subject := range sortedSubjects(inherited) {
+				if !subjects.Has(subject) {
+					subjects.Insert(subject)
+					edge.Subjects = append(edge.Subjects, subject)
+				}
+				for _, consumer := range sets.List(inherited[subject]) {
+					if !slices.Contains(kg.Provenance.Consumers[subject], consumer) {
+						kg.Provenance.Consumers[subject] = append(kg.Provenance.Consumers[subject], consumer)
+					}
+				}
+			}
+			edges = append(edges, edge)
+		}
+		kg.Edges = edges
+		chained = append(chained, kg)
+	}
+	return chained
+}
+
+type chainer struct {
+	maxDepth int
+	// direct are the edges of every key, as computed from its own
+	// consumers.
+	direct map[string]map[types.NamespacedName]store.Edge
+	graphs map[string]KeyGraph
+}
+
+// inherit adds to inherited the subjects of the upstream keys of key whose
+// targets are sources, along with the ClusterReferenceConsumers making them
+// consumers. path are the keys of the chain so far.
+func (c *chainer) inherit(key string, sources []types.NamespacedName, path sets.Set[string], inherited map[v1a1.Subject]sets.Set[string]) {
+	if path.Len() >= c.maxDepth {
+		return
+	}
+	for _, upstream := range sets.List(sets.KeySet(c.direct)) {
+		if path.Has(upstream) || keyTo(upstream) != keyFrom(key) {
+			continue
+		}
+		for _, source := range sources {
+			edge, ok := c.direct[upstream][source]
+			if !ok {
+				continue
+			}
+			for _, subject := range edge.Subjects {
+				if _, ok := inherited[subject]; !ok {
+					inherited[subject] = make(sets.Set[string])
+				}
+				inherited[subject].Insert(c.graphs[upstream].Provenance.Consumers[subject]...)
+			}
+			c.inherit(upstream, edge.Sources, path.Union(sets.New(upstream)), inherited)
+		}
+	}
+}
+
+func keyFrom(key string) string {
+	return strings.Split(key, ";")[0]
+}
+
+func keyTo(key string) string {
+	return strings.Split(key, ";")[1]
+}
+
+func copyConsumers(consumers map[v1a1.Subject][]string) map[v1a1.Subject][]string {
+	out := make(map[v1a1.Subject][]string, len(consumers))
+	for subject, names := range consumers {
+		out[subject] = append([]string{}, names...)
+	}
+	return out
+}
+
+func sortedSubjects(subjects map[v1a1.Subject]sets.Set[string]) []v1a1.Subject {
+	out := make([]v1a1.Subject, 0, len(subjects))
+	for subject := range subjects {
+		out = append(out, subject)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind < out[j].Kind
+		}
+		if out[i].Namespace != out[j].Namespace {
+			return out[i].Namespace < out[j].Namespace
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+const (
+	gatewayListenerSetsKey = "gateway.networking.k8s.io/gateways;gateway.networking.k8s.io/listenersets;listeners"
+	listenerSetSecretsKey  = "gateway.networking.k8s.io/listenersets;/secrets;tls-serving"
+	listenerSetGatewaysKey = "gateway.networking.k8s.io/listenersets;gateway.networking.k8s.io/gateways;parent"
+)
+
+var (
+	bob   = v1a1.Subject{Kind: "User", Name: "bob"}
+	carol = v1a1.Subject{Kind: "User", Name: "carol"}
+)
+
+// keyGraph returns the graph of key with a single edge, consumed by subject
+// through a ClusterReferenceConsumer of the same name.
+func keyGraph(key string, target types.NamespacedName, subject v1a1.Subject, sources ...types.NamespacedName) KeyGraph {
+	return KeyGraph{
+		Key:        key,
+		Edges:      []store.Edge{{Target: target, Subjects: []v1a1.Subject{subject}, Sources: sources}},
+		Provenance: store.KeyProvenance{Consumers: map[v1a1.Subject][]string{subject: {subject.Name}}},
+	}
+}
+
+func TestChain(t *testing.T) {
+	gatewayListenerSets := keyGraph(gatewayListenerSetsKey, nn("demo", "ls"), user, nn("demo", "gw"))
+	listenerSetSecrets := keyGraph(listenerSetSecretsKey, nn("demo", "tls"), bob, nn("demo", "ls"))
+
+	tests := []struct {
+		name     string
+		graphs   []KeyGraph
+		maxDepth int
+		// want are the subjects of the edge of each key.
+		want map[string][]v1a1.Subject
+		// wantConsumers are only checked for the keys they have.
+		wantConsumers map[string]map[v1a1.Subject][]string
+	}{
+		{
+			name:     "chained",
+			graphs:   []KeyGraph{gatewayListenerSets, listenerSetSecrets},
+			maxDepth: DefaultMaxChainDepth,
+			want: map[string][]v1a1.Subject{
+				gatewayListenerSetsKey: {user},
+				listenerSetSecretsKey:  {bob, user},
+			},
+			wantConsumers: map[string]map[v1a1.Subject][]string{
+				gatewayListenerSetsKey: {user: {"alice"}},
+				listenerSetSecretsKey:  {bob: {"bob"}, user: {"alice"}},
+			},
+		},
+		{
+			name:     "chaining disabled",
+			graphs:   []KeyGraph{gatewayListenerSets, listenerSetSecrets},
+			maxDepth: 1,
+			want: map[string][]v1a1.Subject{
+				gatewayListenerSetsKey: {user},
+				listenerSetSecretsKey:  {bob},
+			},
+		},
+		{
+			name: "revoked upstream reference",
+			graphs: []KeyGraph{
+				{Key: gatewayListenerSetsKey, Provenance: store.KeyProvenance{Consumers: map[v1a1.Subject][]string{user: {"alice"}}}},
+				listenerSetSecrets,
+			},
+			maxDepth: DefaultMaxChainDepth,
+			want: map[string][]v1a1.Subject{
+				listenerSetSecretsKey: {bob},
+			},
+		},
+		{
+			name: "cycle",
+			graphs: []KeyGraph{
+				gatewayListenerSets,
+				listenerSetSecrets,
+				keyGraph(listenerSetGatewaysKey, nn("demo", "gw"), carol, nn("demo", "ls")),
+			},
+			maxDepth: DefaultMaxChainDepth,
+			want: map[string][]v1a1.Subject{
+				gatewayListenerSetsKey: {user, carol},
+				listenerSetSecretsKey:  {bob, user, carol},
+				listenerSetGatewaysKey: {carol, user},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graphs := Chain(tt.graphs, tt.maxDepth)
+			got := map[string][]v1a1.Subject{}
+			consumers := map[string]map[v1a1.Subject][]string{}
+			for _, kg := range graphs {
+				for _, edge := range kg.Edges {
+					got[kg.Key] = edge.Subjects
+				}
+				consumers[kg.Key] = kg.Provenance.Consumers
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Chain() subjects mismatch (-want +got):\n%s", diff)
+			}
+			for key, want := range tt.wantConsumers {
+				if diff := cmp.Diff(want, consumers[key]); diff != "" {
+					t.Errorf("Chain() consumers of %s mismatch (-want +got):\n%s", key, diff)
+				}
+			}
+		})
+	}
+
+	// The input graphs are left untouched.
+	if diff := cmp.Diff([]v1a1.Subject{bob}, listenerSetSecrets.Edges[0].Subjects); diff != "" {
+		t.Errorf("Chain() modified its input (-want +got):\n%s", diff)
+	}
+}
+
+func TestChainedKeys(t *testing.T) {
+	listenerSets := v1a1.GroupResource{Group: "gateway.networking.k8s.io", Resource: "listenersets"}
+	crgs := []v1a1.ClusterReferenceGrant{
+		grant("gateways", gateways, listenerSets, "$.spec.listenerSetRefs[*]", "listeners"),
+		grant("listenersets", listenerSets, secrets, certificateRefs, "tls-serving"),
+		grant("gateway-configmaps", gateways, v1a1.GroupResource{Resource: "configmaps"}, "$.spec.infrastructure.parametersRef", "parameters"),
+	}
+
+	tests := []struct {
+		name string
+		keys sets.Set[string]
+		want sets.Set[string]
+	}{
+		{
+			name: "downstream",
+			keys: sets.New(gatewayListenerSetsKey),
+			want: sets.New(gatewayListenerSetsKey, listenerSetSecretsKey),
+		},
+		{
+			name: "upstream",
+			keys: sets.New(listenerSetSecretsKey),
+			want: sets.New(gatewayListenerSetsKey, listenerSetSecretsKey),
+		},
+		{
+			name: "not chained",
+			keys: sets.New("gateway.networking.k8s.io/gateways;/configmaps;parameters"),
+			want: sets.New("gateway.networking.k8s.io/gateways;/configmaps;parameters"),
+		},
+		{
+			name: "no longer defined",
+			keys: sets.New("gateway.networking.k8s.io/gateways;gateway.networking.k8s.io/listenersets;other"),
+			want: sets.New("gateway.networking.k8s.io/gateways;gateway.networking.k8s.io/listenersets;other", listenerSetSecretsKey, gatewayListenerSetsKey),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(sets.List(tt.want), sets.List(ChainedKeys(crgs, tt.keys))); diff != "" {
+				t.Errorf("ChainedKeys() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,431 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package graph computes the reference graph from ClusterReferenceConsumers,
+// ClusterReferenceGrants, ReferenceGrants and the From objects they refer to,
+// less the targets excluded by ReferencePolicies.
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/jsonpath"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+// KeyGraph is the graph computed for a from-to-for key.
+type KeyGraph struct {
+	Key        string
+	Edges      []store.Edge
+	Provenance store.KeyProvenance
+	// Owners are the ClusterReferenceGrants defining reference paths for the
+	// key.
+	Owners []v1a1.ClusterReferenceGrant
+	// SkippedConsumers are the ClusterReferenceConsumers of the key whose
+	// subject couldn't be rendered, by name, with the error.
+	SkippedConsumers map[string]error
+}
+
+// ScopeFunc reports whether a resource is cluster-scoped.
+type ScopeFunc func(gr schema.GroupResource) (clusterScoped bool, err error)
+
+// RESTMapperScope returns a ScopeFunc looking up the scope of resources in
+// mapper.
+func RESTMapperScope(mapper meta.RESTMapper) ScopeFunc {
+	return func(gr schema.GroupResource) (bool, error) {
+		gvk, err := mapper.KindFor(gr.WithVersion(""))
+		if err != nil {
+			return false, err
+		}
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return false, err
+		}
+		return mapping.Scope.Name() == meta.RESTScopeNameRoot, nil
+	}
+}
+
+// Compute computes the graph of every from-to-for key defined by crgs. The
+// From objects of each key are looked up in objects by group and resource,
+// and the scope of its To resource with scope. A nil scope treats every
+// resource as namespaced. Targets excluded by policies are dropped, looking
+// them up with target when needed, before keys are chained across at most
+// maxChainDepth keys. It doesn't access the cluster.
+func Compute(crcs []v1a1.ClusterReferenceConsumer, rcs []v1a1.ReferenceConsumer, crgs []v1a1.ClusterReferenceGrant, rgs []v1a1.ReferenceGrant, policies []v1a1.ReferencePolicy, objects map[schema.GroupResource][]unstructured.Unstructured, scope ScopeFunc, target TargetFunc, maxChainDepth int) ([]KeyGraph, error) {
+	keys := make(sets.Set[string])
+	for _, crg := range crgs {
+		keys = keys.Union(ApplicableKeys(crgs, fmt.Sprintf("%s/%s", crg.From.Group, crg.From.Resource)))
+	}
+	graphs := make([]KeyGraph, 0, keys.Len())
+	for _, key := range sets.List(keys) {
+		gvr, _ := FromResource(key, crgs)
+		toClusterScoped := false
+		if scope != nil {
+			var err error
+			toClusterScoped, err = scope(ToResource(key))
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up the scope of %s: %w", ToResource(key), err)
+			}
+		}
+		kg, err := ComputeKey(key, toClusterScoped, crcs, rcs, crgs, rgs, objects[gvr.GroupResource()])
+		if err != nil {
+			return nil, err
+		}
+		graphs = append(graphs, Exclude(kg, policies, target))
+	}
+	return Chain(graphs, maxChainDepth), nil
+}
+
+// ApplicableKeys returns the from-to-for keys defined by crgs for the From
+// "group/resource".
+func ApplicableKeys(crgs []v1a1.ClusterReferenceGrant, from string) sets.Set[string] {
+	keys := make(sets.Set[string])
+	for _, crg := range crgs {
+		origin := fmt.Sprintf("%s/%s", crg.From.Group, crg.From.Resource)
+		// Early exit if origin is not the same
+		if from != origin {
+			continue
+		}
+		if len(crg.Versions) == 0 {
+			continue
+		}
+		for _, ref := range crg.Versions[0].References {
+			target := fmt.Sprintf("%s/%s", ref.To.Group, ref.To.Resource)
+			key := fmt.Sprintf("%s;%s;%s", origin, target, ref.For)
+			keys.Insert(key)
+		}
+	}
+	return keys
+}
+
+// FromResource returns the From resource of a from-to-for key, at the version
+// its ClusterReferenceGrants follow references at. It returns false if no
+// ClusterReferenceGrant defines a reference path for the key.
+func FromResource(key string, crgs []v1a1.ClusterReferenceGrant) (schema.GroupVersionResource, bool) {
+	origin := strings.Split(key, ";")[0]
+	var fromVersion string
+	found := false
+	for _, crg := range crgs {
+		if origin != fmt.Sprintf("%s/%s", crg.From.Group, crg.From.Resource) {
+			continue
+		}
+		if len(crg.Versions) == 0 {
+			continue
+		}
+		fromVersion = crg.Versions[0].Version
+		for _, ref := range crg.Versions[0].References {
+			if fmt.Sprintf("%s;%s/%s;%s", origin, ref.To.Group, ref.To.Resource, ref.For) == key {
+				found = true
+			}
+		}
+	}
+	group, resource, _ := strings.Cut(origin, "/")
+	return schema.GroupVersionResource{Group: group, Version: fromVersion, Resource: resource}, found
+}
+
+// ToResource returns the To resource of a from-to-for key.
+func ToResource(key string) schema.GroupResource {
+	group, resource, _ := strings.Cut(strings.Split(key, ";")[1], "/")
+	return schema.GroupResource{Group: group, Resource: resource}
+}
+
+// ComputeKey computes the graph of a from-to-for key from consumers, grants,
+// reference grants and the From objects of the key. ReferenceConsumers are
+// only consumers of the targets referenced from their own namespace. Targets of a
+// cluster-scoped To resource have no namespace, and need no ReferenceGrant.
+// References from cluster-scoped From objects to namespaced targets always
+// need one. ClusterReferenceConsumers whose subject can't be rendered are
+// skipped, so that they can't keep the key from being updated. It doesn't
+// access the cluster.
+func ComputeKey(fromToForKey string, toClusterScoped bool, crcs []v1a1.ClusterReferenceConsumer, rcs []v1a1.ReferenceConsumer, crgs []v1a1.ClusterReferenceGrant, rgs []v1a1.ReferenceGrant, objects []unstructured.Unstructured) (KeyGraph, error) {
+	kg := KeyGraph{
+		Key:        fromToForKey,
+		Provenance: store.KeyProvenance{Consumers: map[v1a1.Subject][]string{}},
+	}
+	crcSubjects := []v1a1.Subject{}
+	// edgeSubjects are rendered for each edge.
+	edgeSubjects := []consumerSubject{}
+	for _, crc := range crcs {
+		for _, ref := range crc.References {
+			origin := fmt.Sprintf("%s/%s", ref.From.Group, ref.From.Resource)
+			target := fmt.Sprintf("%s/%s", ref.To.Group, ref.To.Resource)
+			key := fmt.Sprintf("%s;%s;%s", origin, target, ref.For)
+			if key == fromToForKey {
+				cs, err := newConsumerSubject(crc, ref)
+				if err != nil {
+					kg.skipConsumer(crc.Name, err)
+					break
+				}
+				if cs.perEdge() {
+					edgeSubjects = append(edgeSubjects, cs)
+				} else {
+					crcSubjects = append(crcSubjects, cs.subject)
+					kg.addConsumer(cs.subject, cs)
+				}
+				break
+			}
+		}
+	}
+	for _, rc := range rcs {
+		for _, ref := range rc.References {
+			origin := fmt.Sprintf("%s/%s", ref.From.Group, ref.From.Resource)
+			target := fmt.Sprintf("%s/%s", ref.To.Group, ref.To.Resource)
+			key := fmt.Sprintf("%s;%s;%s", origin, target, ref.For)
+			if key == fromToForKey {
+				edgeSubjects = append(edgeSubjects, newReferenceConsumerSubject(rc))
+				break
+			}
+		}
+	}
+	// map between FromNamespace to a map of ToNamespace to ResourceName to the
+	// ReferenceGrants allowing it for this particular fromToFor key
+	crossNamespaceGrants := map[string]map[string]map[string][]types.NamespacedName{}
+	for _, rg := range rgs {
+		origin := fmt.Sprintf("%s/%s", rg.From.Group, rg.From.Resource)
+		target := fmt.Sprintf("%s/%s", rg.To.Group, rg.To.Resource)
+		key := fmt.Sprintf("%s;%s;%s", origin, target, rg.For)
+		if key == fromToForKey {
+			if _, ok := crossNamespaceGrants[rg.From.Namespace]; !ok {
+				crossNamespaceGrants[rg.From.Namespace] = make(map[string]map[string][]types.NamespacedName)
+			}
+			if _, ok := crossNamespaceGrants[rg.From.Namespace][rg.Namespace]; !ok {
+				crossNamespaceGrants[rg.From.Namespace][rg.Namespace] = make(map[string][]types.NamespacedName)
+			}
+			for _, name := range rg.To.Names {
+				crossNamespaceGrants[rg.From.Namespace][rg.Namespace][name] = append(crossNamespaceGrants[rg.From.Namespace][rg.Namespace][name], types.NamespacedName{Namespace: rg.Namespace, Name: rg.Name})
+			}
+		}
+	}
+
+	referencePaths := []v1a1.ReferencePath{}
+	for _, crg := range crgs {
+		origin := fmt.Sprintf("%s/%s", crg.From.Group, crg.From.Resource)
+		// Early exit if origin is not the same
+		if strings.Split(fromToForKey, ";")[0] != origin {
+			continue
+		}
+		if len(crg.Versions) == 0 {
+			continue
+		}
+		// TODO: Handle versions, currently taking only the first versions in the list
+		for _, ref := range crg.Versions[0].References {
+			target := fmt.Sprintf("%s/%s", ref.To.Group, ref.To.Resource)
+			key := fmt.Sprintf("%s;%s;%s", origin, target, ref.For)
+			if key == fromToForKey {
+				referencePaths = append(referencePaths, ref)
+				if len(kg.Owners) == 0 || kg.Owners[len(kg.Owners)-1].Name != crg.Name {
+					kg.Owners = append(kg.Owners, crg)
+					kg.Provenance.ClusterReferenceGrants = append(kg.Provenance.ClusterReferenceGrants, crg.Name)
+				}
+			}
+		}
+	}
+
+	// At this point, all references declaration in ReferencePaths are relevant for us.
+	// Follow Reference Paths to recalculate the graph.
+	var referencedResources []reference
+	for _, refPath := range referencePaths {
+		refResources, err := getReferences(objects, refPath.Path)
+		if err != nil {
+			return kg, fmt.Errorf("failed to follow references for path %s: %w", refPath.Path, err)
+		}
+		referencedResources = append(referencedResources, refResources...)
+	}
+
+	// For the first POC, we want to recalculate the from-to-for key e2e, so
+	// everything computed for the key is replaced.
+	edges := map[types.NamespacedName]*store.Edge{}
+	targets := []types.NamespacedName{}
+	for _, refResource := range referencedResources {
+		if toClusterScoped {
+			refResource.ToNamespace = ""
+		} else if refResource.ToNamespace == "" {
+			// References of cluster-scoped From objects to namespaced
+			// targets must name the namespace of the target.
+			continue
+		}
+		target := types.NamespacedName{Namespace: refResource.ToNamespace, Name: refResource.Name}
+		source := types.NamespacedName{Namespace: refResource.FromNamespace, Name: refResource.FromName}
+		var referenceGrants []types.NamespacedName
+		// Cluster-scoped From objects have no namespace, so their references
+		// to namespaced targets always need a ReferenceGrant, from the empty
+		// namespace.
+		if !toClusterScoped && refResource.FromNamespace != refResource.ToNamespace {
+			referenceGrants = crossNamespaceGrants[refResource.FromNamespace][refResource.ToNamespace][refResource.Name]
+			if len(referenceGrants) == 0 {
+				kg.Provenance.Ungranted = append(kg.Provenance.Ungranted, store.Edge{Target: target, Sources: []types.NamespacedName{source}})
+				continue
+			}
+		}
+		if _, ok := edges[target]; !ok {
+			edges[target] = &store.Edge{Target: target, Subjects: crcSubjects}
+			targets = append(targets, target)
+		}
+		edges[target].Sources = append(edges[target].Sources, source)
+		edges[target].ReferenceGrants = append(edges[target].ReferenceGrants, referenceGrants...)
+	}
+	kg.Edges = make([]store.Edge, 0, len(targets))
+	for _, target := range targets {
+		edge := *edges[target]
+		if len(edgeSubjects) > 0 {
+			edge.Subjects = kg.renderSubjects(edge, edgeSubjects)
+		}
+		kg.Edges = append(kg.Edges, edge)
+	}
+	return kg, nil
+}
+
+// addConsumer records that cs makes subject a consumer of the key.
+func (kg *KeyGraph) addConsumer(subject v1a1.Subject, cs consumerSubject) {
+	if !slices.Contains(kg.Provenance.Consumers[subject], cs.consumer) {
+		kg.Provenance.Consumers[subject] = append(kg.Provenance.Consumers[subject], cs.consumer)
+	}
+	if len(cs.subresources) > 0 {
+		if kg.Provenance.Subresources == nil {
+			kg.Provenance.Subresources = map[v1a1.Subject][]string{}
+		}
+		for _, subresource := range cs.subresources {
+			if !slices.Contains(kg.Provenance.Subresources[subject], subresource) {
+				kg.Provenance.Subresources[subject] = append(kg.Provenance.Subresources[subject], subresource)
+			}
+		}
+	}
+}
+
+// skipConsumer records that a ClusterReferenceConsumer is skipped.
+func (kg *KeyGraph) skipConsumer(name string, err error) {
+	if kg.SkippedConsumers == nil {
+		kg.SkippedConsumers = map[string]error{}
+	}
+	kg.SkippedConsumers[name] = err
+}
+
+// renderSubjects returns the subjects of edge along with the per-edge
+// subjects rendered for the namespace of each of its sources. Sources
+// without a namespace render no subject, and consumers failing to render are
+// skipped.
+func (kg *KeyGraph) renderSubjects(edge store.Edge, perEdge []consumerSubject) []v1a1.Subject {
+	subjects := append([]v1a1.Subject{}, edge.Subjects...)
+	namespaces := sets.New[string]()
+	for _, source := range edge.Sources {
+		if source.Namespace != "" {
+			namespaces.Insert(source.Namespace)
+		}
+	}
+	for _, cs := range perEdge {
+		for _, namespace := range sets.List(namespaces) {
+			subject, ok, err := cs.render(namespace)
+			if err != nil {
+				kg.skipConsumer(cs.consumer, err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if !slices.Contains(subjects, subject) {
+				subjects = append(subjects, subject)
+			}
+			kg.addConsumer(subject, cs)
+		}
+	}
+	return subjects
+}
+
+type reference struct {
+	Group         string
+	Resource      string
+	FromNamespace string
+	FromName      string
+	ToNamespace   string
+	Name          string
+}
+
+// getReferences follows path in every object. References that can't be
+// decoded or have no name are skipped.
+func getReferences(objects []unstructured.Unstructured, path string) ([]reference, error) {
+	refs := []reference{}
+	j := jsonpath.New("references").AllowMissingKeys(true)
+	if err := j.Parse(fmt.Sprintf("{%s}", path)); err != nil {
+		return refs, fmt.Errorf("error parsing JSON Path: %w", err)
+	}
+	for _, item := range objects {
+		results := new(bytes.Buffer)
+		err := j.Execute(results, item.UnstructuredContent())
+		if err != nil {
+			return refs, fmt.Errorf("error finding results with JSON Path: %w", err)
+		}
+
+		rawRefs := strings.Split(results.String(), " ")
+
+		for _, rr := range rawRefs {
+			jr := map[string]string{}
+			if len(rr) == 0 {
+				continue
+			}
+			if err := json.Unmarshal([]byte(rr), &jr); err != nil {
+				continue
+			}
+			// The part below is commented in favour of the decision to
+			// requiring the ClusterRefGrant to specify the group and resource and
+			// limit the json path to only pull names that match that group and resource or kind.
+			// This is not feasible using the current jsonPath implementation so we are likely to use CEL for this.
+
+			// group, hasGroup := jr["group"]
+			// if !hasGroup {
+			// 	c.log.Info("Missing group in reference", "ref", jr)
+			// 	continue
+			// }
+			// resource, hasResource := jr["resource"]
+			// if !hasResource {
+			// 	kind, hasKind := jr["kind"]
+			// 	if !hasKind {
+			// 		c.log.Info("Missing kind or resource in reference", "ref", jr)
+			// 		continue
+			// 	}
+			// 	gvr, _ := meta.UnsafeGuessKindToResource(schema.GroupVersionKind{Group: group, Version: "v1", Kind: kind})
+			// 	resource = gvr.Resource
+			// }
+
+			namespace, hasNamespace := jr["namespace"]
+			if !hasNamespace {
+				namespace = item.GetNamespace()
+			}
+
+			name, hasName := jr["name"]
+			if !hasName {
+				continue
+			}
+			refs = append(refs, reference{
+				FromNamespace: item.GetNamespace(),
+				FromName:      item.GetName(),
+				ToNamespace:   namespace,
+				Name:          name,
+			})
+		}
+	}
+
+	return refs, nil
+}
@@ -0,0 +1,521 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+const (
+	gatewaySecretsKey = "gateway.networking.k8s.io/gateways;/secrets;tls-serving"
+	certificateRefs   = "$.spec.listeners[*].tls.certificateRefs[*]"
+)
+
+var (
+	gateways = v1a1.GroupResource{Group: "gateway.networking.k8s.io", Resource: "gateways"}
+	secrets  = v1a1.GroupResource{Group: "", Resource: "secrets"}
+	user     = v1a1.Subject{Kind: "User", Name: "alice"}
+)
+
+func consumer(name string, subject v1a1.Subject, from, to v1a1.GroupResource, purpose string) v1a1.ClusterReferenceConsumer {
+	return v1a1.ClusterReferenceConsumer{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Subject:    subject,
+		References: []v1a1.ConsumerReference{{From: from, To: to, For: purpose}},
+	}
+}
+
+func referenceConsumer(namespace, name, serviceAccountName string, from, to v1a1.GroupResource, purpose string) v1a1.ReferenceConsumer {
+	return v1a1.ReferenceConsumer{
+		ObjectMeta:         metav1.ObjectMeta{Namespace: namespace, Name: name},
+		ServiceAccountName: serviceAccountName,
+		References:         []v1a1.ReferenceConsumerReference{{From: from, To: to, For: purpose}},
+	}
+}
+
+// withSubresources sets the subresources of the references of crc.
+func withSubresources(crc v1a1.ClusterReferenceConsumer, subresources ...string) v1a1.ClusterReferenceConsumer {
+	for i := range crc.References {
+		crc.References[i].Subresources = subresources
+	}
+	return crc
+}
+
+func grant(name string, from, to v1a1.GroupResource, path, purpose string) v1a1.ClusterReferenceGrant {
+	return v1a1.ClusterReferenceGrant{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		From:       from,
+		Versions: []v1a1.VersionedReferencePaths{{
+			Version:    "v1",
+			References: []v1a1.ReferencePath{{Path: path, To: to, For: purpose}},
+		}},
+	}
+}
+
+func referenceGrant(namespace, name, fromNamespace string, names ...string) v1a1.ReferenceGrant {
+	return v1a1.ReferenceGrant{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		From:       v1a1.GroupResourceNamespace{Group: gateways.Group, Resource: gateways.Resource, Namespace: fromNamespace},
+		To:         v1a1.ReferenceGrantTo{Group: secrets.Group, Resource: secrets.Resource, Names: names},
+		For:        "tls-serving",
+	}
+}
+
+// gateway returns a Gateway referencing secrets. References without a
+// namespace are to secrets in the Gateway namespace.
+func gateway(namespace, name string, secretRefs ...types.NamespacedName) unstructured.Unstructured {
+	refs := []interface{}{}
+	for _, ref := range secretRefs {
+		r := map[string]interface{}{"group": "", "kind": "Secret", "name": ref.Name}
+		if ref.Namespace != "" {
+			r["namespace"] = ref.Namespace
+		}
+		refs = append(refs, r)
+	}
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "gateway.networking.k8s.io/v1",
+		"kind":       "Gateway",
+		"metadata":   map[string]interface{}{"namespace": namespace, "name": name},
+		"spec": map[string]interface{}{
+			"listeners": []interface{}{
+				map[string]interface{}{"name": "https", "tls": map[string]interface{}{"certificateRefs": refs}},
+			},
+		},
+	}}
+}
+
+func nn(namespace, name string) types.NamespacedName {
+	return types.NamespacedName{Namespace: namespace, Name: name}
+}
+
+func TestComputeKey(t *testing.T) {
+	tests := []struct {
+		name           string
+		crcs           []v1a1.ClusterReferenceConsumer
+		rcs            []v1a1.ReferenceConsumer
+		crgs           []v1a1.ClusterReferenceGrant
+		rgs            []v1a1.ReferenceGrant
+		objects        []unstructured.Unstructured
+		wantEdges      []store.Edge
+		wantProvenance store.KeyProvenance
+		wantOwners     []string
+	}{
+		{
+			name:    "same namespace reference",
+			crcs:    []v1a1.ClusterReferenceConsumer{consumer("alice", user, gateways, secrets, "tls-serving")},
+			crgs:    []v1a1.ClusterReferenceGrant{grant("gateways", gateways, secrets, certificateRefs, "tls-serving")},
+			objects: []unstructured.Unstructured{gateway("demo", "gw", nn("", "tls"))},
+			wantEdges: []store.Edge{
+				{Target: nn("demo", "tls"), Subjects: []v1a1.Subject{user}, Sources: []types.NamespacedName{nn("demo", "gw")}},
+			},
+			wantProvenance: store.KeyProvenance{
+				Consumers:              map[v1a1.Subject][]string{user: {"alice"}},
+				ClusterReferenceGrants: []string{"gateways"},
+			},
+			wantOwners: []string{"gateways"},
+		},
+		{
+			name:    "cross namespace reference allowed by a ReferenceGrant",
+			crcs:    []v1a1.ClusterReferenceConsumer{consumer("alice", user, gateways, secrets, "tls-serving")},
+			crgs:    []v1a1.ClusterReferenceGrant{grant("gateways", gateways, secrets, certificateRefs, "tls-serving")},
+			rgs:     []v1a1.ReferenceGrant{referenceGrant("default", "demo-gateways", "demo", "tls")},
+			objects: []unstructured.Unstructured{gateway("demo", "gw", nn("default", "tls"))},
+			wantEdges: []store.Edge{{
+				Target:          nn("default", "tls"),
+				Subjects:        []v1a1.Subject{user},
+				Sources:         []types.NamespacedName{nn("demo", "gw")},
+				ReferenceGrants: []types.NamespacedName{nn("default", "demo-gateways")},
+			}},
+			wantProvenance: store.KeyProvenance{
+				Consumers:              map[v1a1.Subject][]string{user: {"alice"}},
+				ClusterReferenceGrants: []string{"gateways"},
+			},
+			wantOwners: []string{"gateways"},
+		},
+		{
+			name:      "cross namespace reference without a ReferenceGrant",
+			crcs:      []v1a1.ClusterReferenceConsumer{consumer("alice", user, gateways, secrets, "tls-serving")},
+			crgs:      []v1a1.ClusterReferenceGrant{grant("gateways", gateways, secrets, certificateRefs, "tls-serving")},
+			objects:   []unstructured.Unstructured{gateway("demo", "gw", nn("default", "tls"))},
+			wantEdges: []store.Edge{},
+			wantProvenance: store.KeyProvenance{
+				Consumers:              map[v1a1.Subject][]string{user: {"alice"}},
+				ClusterReferenceGrants: []string{"gateways"},
+				Ungranted:              []store.Edge{{Target: nn("default", "tls"), Sources: []types.NamespacedName{nn("demo", "gw")}}},
+			},
+			wantOwners: []string{"gateways"},
+		},
+		{
+			name: "ReferenceGrant for another name, namespace or purpose",
+			crcs: []v1a1.ClusterReferenceConsumer{consumer("alice", user, gateways, secrets, "tls-serving")},
+			crgs: []v1a1.ClusterReferenceGrant{grant("gateways", gateways, secrets, certificateRefs, "tls-serving")},
+			rgs: func() []v1a1.ReferenceGrant {
+				otherPurpose := referenceGrant("default", "other-purpose", "demo", "tls")
+				otherPurpose.For = "other"
+				return []v1a1.ReferenceGrant{
+					referenceGrant("default", "other-name", "demo", "other"),
+					referenceGrant("default", "other-namespace", "other", "tls"),
+					referenceGrant("other", "other-target-namespace", "demo", "tls"),
+					otherPurpose,
+				}
+			}(),
+			objects:   []unstructured.Unstructured{gateway("demo", "gw", nn("default", "tls"))},
+			wantEdges: []store.Edge{},
+			wantProvenance: store.KeyProvenance{
+				Consumers:              map[v1a1.Subject][]string{user: {"alice"}},
+				ClusterReferenceGrants: []string{"gateways"},
+				Ungranted:              []store.Edge{{Target: nn("default", "tls"), Sources: []types.NamespacedName{nn("demo", "gw")}}},
+			},
+			wantOwners: []string{"gateways"},
+		},
+		{
+			name:    "cluster-scoped source allowed by a ReferenceGrant",
+			crcs:    []v1a1.ClusterReferenceConsumer{consumer("alice", user, gateways, secrets, "tls-serving")},
+			crgs:    []v1a1.ClusterReferenceGrant{grant("gateways", gateways, secrets, certificateRefs, "tls-serving")},
+			rgs:     []v1a1.ReferenceGrant{referenceGrant("demo", "cluster-gateways", "", "tls")},
+			objects: []unstructured.Unstructured{gateway("", "gw", nn("demo", "tls"), nn("demo", "other-tls"), nn("", "no-namespace"))},
+			wantEdges: []store.Edge{{
+				Target:          nn("demo", "tls"),
+				Subjects:        []v1a1.Subject{user},
+				Sources:         []types.NamespacedName{nn("", "gw")},
+				ReferenceGrants: []types.NamespacedName{nn("demo", "cluster-gateways")},
+			}},
+			wantProvenance: store.KeyProvenance{
+				Consumers:              map[v1a1.Subject][]string{user: {"alice"}},
+				ClusterReferenceGrants: []string{"gateways"},
+				Ungranted:              []store.Edge{{Target: nn("demo", "other-tls"), Sources: []types.NamespacedName{nn("", "gw")}}},
+			},
+			wantOwners: []string{"gateways"},
+		},
+		{
+			name: "ServiceAccount subjects are normalized to users",
+			crcs: []v1a1.ClusterReferenceConsumer{
+				consumer("controller", v1a1.Subject{Kind: "ServiceAccount", Namespace: "demo", Name: "controller"}, gateways, secrets, "tls-serving"),
+			},
+			crgs:    []v1a1.ClusterReferenceGrant{grant("gateways", gateways, secrets, certificateRefs, "tls-serving")},
+			objects: []unstructured.Unstructured{gateway("demo", "gw", nn("", "tls"))},
+			wantEdges: []store.Edge{{
+				Target:   nn("demo", "tls"),
+				Subjects: []v1a1.Subject{{Kind: "User", Name: "system:serviceaccount:demo:controller"}},
+				Sources:  []types.NamespacedName{nn("demo", "gw")},
+			}},
+			wantProvenance: store.KeyProvenance{
+				Consumers:              map[v1a1.Subject][]string{{Kind: "User", Name: "system:serviceaccount:demo:controller"}: {"controller"}},
+				ClusterReferenceGrants: []string{"gateways"},
+			},
+			wantOwners: []string{"gateways"},
+		},
+		{
+			name: "templated subjects",
+			crcs: []v1a1.ClusterReferenceConsumer{
+				consumer("gateway", v1a1.Subject{Kind: "ServiceAccount", Namespace: "{{.FromNamespace}}", Name: "gateway"}, gateways, secrets, "tls-serving"),
+				consumer("alice", user, gateways, secrets, "tls-serving"),
+			},
+			crgs: []v1a1.ClusterReferenceGrant{grant("gateways", gateways, secrets, certificateRefs, "tls-serving")},
+			objects: []unstructured.Unstructured{
+				gateway("demo", "gw", nn("", "tls")),
+				gateway("other", "gw", nn("", "tls")),
+			},
+			wantEdges: []store.Edge{
+				{
+					Target:   nn("demo", "tls"),
+					Subjects: []v1a1.Subject{user, {Kind: "User", Name: "system:serviceaccount:demo:gateway"}},
+					Sources:  []types.NamespacedName{nn("demo", "gw")},
+				},
+				{
+					Target:   nn("other", "tls"),
+					Subjects: []v1a1.Subject{user, {Kind: "User", Name: "system:serviceaccount:other:gateway"}},
+					Sources:  []types.NamespacedName{nn("other", "gw")},
+				},
+			},
+			wantProvenance: store.KeyProvenance{
+				Consumers: map[v1a1.Subject][]string{
+					user: {"alice"},
+					{Kind: "User", Name: "system:serviceaccount:demo:gateway"}:  {"gateway"},
+					{Kind: "User", Name: "system:serviceaccount:other:gateway"}: {"gateway"},
+				},
+				ClusterReferenceGrants: []string{"gateways"},
+			},
+			wantOwners: []string{"gateways"},
+		},
+		{
+			name: "ReferenceConsumers of the namespace of the From objects",
+			rcs: []v1a1.ReferenceConsumer{
+				referenceConsumer("demo", "ingress", "ingress", gateways, secrets, "tls-serving"),
+				referenceConsumer("other", "ingress", "ingress", gateways, secrets, "tls-serving"),
+			},
+			crgs:    []v1a1.ClusterReferenceGrant{grant("gateways", gateways, secrets, certificateRefs, "tls-serving")},
+			rgs:     []v1a1.ReferenceGrant{referenceGrant("shared", "demo-gateways", "demo", "tls")},
+			objects: []unstructured.Unstructured{gateway("demo", "gw", nn("", "tls"), nn("shared", "tls"))},
+			wantEdges: []store.Edge{
+				{
+					Target:   nn("demo", "tls"),
+					Subjects: []v1a1.Subject{{Kind: "User", Name: "system:serviceaccount:demo:ingress"}},
+					Sources:  []types.NamespacedName{nn("demo", "gw")},
+				},
+				{
+					Target:          nn("shared", "tls"),
+					Subjects:        []v1a1.Subject{{Kind: "User", Name: "system:serviceaccount:demo:ingress"}},
+					Sources:         []types.NamespacedName{nn("demo", "gw")},
+					ReferenceGrants: []types.NamespacedName{nn("shared", "demo-gateways")},
+				},
+			},
+			wantProvenance: store.KeyProvenance{
+				Consumers:              map[v1a1.Subject][]string{{Kind: "User", Name: "system:serviceaccount:demo:ingress"}: {"demo/ingress"}},
+				ClusterReferenceGrants: []string{"gateways"},
+			},
+			wantOwners: []string{"gateways"},
+		},
+		{
+			name:    "subresources of consumer references",
+			crcs:    []v1a1.ClusterReferenceConsumer{withSubresources(consumer("alice", user, gateways, secrets, "tls-serving"), "status")},
+			crgs:    []v1a1.ClusterReferenceGrant{grant("gateways", gateways, secrets, certificateRefs, "tls-serving")},
+			objects: []unstructured.Unstructured{gateway("demo", "gw", nn("", "tls"))},
+			wantEdges: []store.Edge{{
+				Target:   nn("demo", "tls"),
+				Subjects: []v1a1.Subject{user},
+				Sources:  []types.NamespacedName{nn("demo", "gw")},
+			}},
+			wantProvenance: store.KeyProvenance{
+				Consumers:              map[v1a1.Subject][]string{user: {"alice"}},
+				Subresources:           map[v1a1.Subject][]string{user: {"status"}},
+				ClusterReferenceGrants: []string{"gateways"},
+			},
+			wantOwners: []string{"gateways"},
+		},
+		{
+			name: "consumers of other keys are ignored",
+			crcs: []v1a1.ClusterReferenceConsumer{
+				consumer("other-purpose", user, gateways, secrets, "other"),
+				consumer("other-to", user, gateways, v1a1.GroupResource{Resource: "configmaps"}, "tls-serving"),
+			},
+			crgs:      []v1a1.ClusterReferenceGrant{grant("gateways", gateways, secrets, certificateRefs, "tls-serving")},
+			objects:   []unstructured.Unstructured{gateway("demo", "gw", nn("", "tls"))},
+			wantEdges: []store.Edge{{Target: nn("demo", "tls"), Subjects: []v1a1.Subject{}, Sources: []types.NamespacedName{nn("demo", "gw")}}},
+			wantProvenance: store.KeyProvenance{
+				Consumers:              map[v1a1.Subject][]string{},
+				ClusterReferenceGrants: []string{"gateways"},
+			},
+			wantOwners: []string{"gateways"},
+		},
+		{
+			name:      "no ClusterReferenceGrant for the key",
+			crcs:      []v1a1.ClusterReferenceConsumer{consumer("alice", user, gateways, secrets, "tls-serving")},
+			crgs:      []v1a1.ClusterReferenceGrant{grant("gateways", gateways, secrets, certificateRefs, "other")},
+			objects:   []unstructured.Unstructured{gateway("demo", "gw", nn("", "tls"))},
+			wantEdges: []store.Edge{},
+			wantProvenance: store.KeyProvenance{
+				Consumers: map[v1a1.Subject][]string{user: {"alice"}},
+			},
+		},
+		{
+			name: "targets referenced by several objects",
+			crcs: []v1a1.ClusterReferenceConsumer{consumer("alice", user, gateways, secrets, "tls-serving")},
+			crgs: []v1a1.ClusterReferenceGrant{grant("gateways", gateways, secrets, certificateRefs, "tls-serving")},
+			objects: []unstructured.Unstructured{
+				gateway("demo", "gw-1", nn("", "tls"), nn("", "other-tls")),
+				gateway("demo", "gw-2", nn("", "tls")),
+			},
+			wantEdges: []store.Edge{
+				{Target: nn("demo", "tls"), Subjects: []v1a1.Subject{user}, Sources: []types.NamespacedName{nn("demo", "gw-1"), nn("demo", "gw-2")}},
+				{Target: nn("demo", "other-tls"), Subjects: []v1a1.Subject{user}, Sources: []types.NamespacedName{nn("demo", "gw-1")}},
+			},
+			wantProvenance: store.KeyProvenance{
+				Consumers:              map[v1a1.Subject][]string{user: {"alice"}},
+				ClusterReferenceGrants: []string{"gateways"},
+			},
+			wantOwners: []string{"gateways"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kg, err := ComputeKey(gatewaySecretsKey, false, tt.crcs, tt.rcs, tt.crgs, tt.rgs, tt.objects)
+			if err != nil {
+				t.Fatalf("ComputeKey() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.wantEdges, kg.Edges); diff != "" {
+				t.Errorf("ComputeKey() edges mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantProvenance, kg.Provenance); diff != "" {
+				t.Errorf("ComputeKey() provenance mismatch (-want +got):\n%s", diff)
+			}
+			var owners []string
+			for _, owner := range kg.Owners {
+				owners = append(owners, owner.Name)
+			}
+			if diff := cmp.Diff(tt.wantOwners, owners); diff != "" {
+				t.Errorf("ComputeKey() owners mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestComputeKeyInvalidPath(t *testing.T) {
+	crgs := []v1a1.ClusterReferenceGrant{grant("gateways", gateways, secrets, "$.spec[", "tls-serving")}
+	objects := []unstructured.Unstructured{gateway("demo", "gw", nn("", "tls"))}
+	if _, err := ComputeKey(gatewaySecretsKey, false, nil, nil, crgs, nil, objects); err == nil {
+		t.Errorf("ComputeKey() expected an error for an invalid path")
+	}
+}
+
+func TestComputeKeyInvalidSubjectTemplate(t *testing.T) {
+	crgs := []v1a1.ClusterReferenceGrant{grant("gateways", gateways, secrets, certificateRefs, "tls-serving")}
+	objects := []unstructured.Unstructured{gateway("demo", "gw", nn("", "tls"))}
+	for _, name := range []string{"system:serviceaccount:{{.FromNamespace:gateway", "system:serviceaccount:{{.Namespace}}:gateway"} {
+		crcs := []v1a1.ClusterReferenceConsumer{
+			consumer("gateway", v1a1.Subject{Kind: "User", Name: name}, gateways, secrets, "tls-serving"),
+			consumer("alice", user, gateways, secrets, "tls-serving"),
+		}
+		kg, err := ComputeKey(gatewaySecretsKey, false, crcs, nil, crgs, nil, objects)
+		if err != nil {
+			t.Fatalf("ComputeKey() error = %v for the subject template %q", err, name)
+		}
+		// Other consumers of the key are still authorized.
+		want := []store.Edge{{Target: nn("demo", "tls"), Subjects: []v1a1.Subject{user}, Sources: []types.NamespacedName{nn("demo", "gw")}}}
+		if diff := cmp.Diff(want, kg.Edges); diff != "" {
+			t.Errorf("ComputeKey() edges mismatch for the subject template %q (-want +got):\n%s", name, diff)
+		}
+		if _, ok := kg.SkippedConsumers["gateway"]; !ok || len(kg.SkippedConsumers) != 1 {
+			t.Errorf("ComputeKey() skipped consumers = %v, want gateway for the subject template %q", kg.SkippedConsumers, name)
+		}
+	}
+}
+
+func TestValidateConsumer(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject v1a1.Subject
+		wantErr bool
+	}{
+		{name: "plain subject", subject: v1a1.Subject{Kind: "ServiceAccount", Namespace: "demo", Name: "gateway"}},
+		{name: "templated namespace", subject: v1a1.Subject{Kind: "ServiceAccount", Namespace: "{{.FromNamespace}}", Name: "gateway"}},
+		{name: "invalid template", subject: v1a1.Subject{Kind: "User", Name: "system:serviceaccount:{{.FromNamespace:gateway"}, wantErr: true},
+		{name: "unknown field", subject: v1a1.Subject{Kind: "User", Name: "system:serviceaccount:{{.Namespace}}:gateway"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConsumer(consumer("gateway", tt.subject, gateways, secrets, "tls-serving"))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConsumer() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestComputeKeyClusterScoped(t *testing.T) {
+	crcs := []v1a1.ClusterReferenceConsumer{consumer("alice", user, gateways, secrets, "tls-serving")}
+	crgs := []v1a1.ClusterReferenceGrant{grant("gateways", gateways, secrets, certificateRefs, "tls-serving")}
+	objects := []unstructured.Unstructured{
+		gateway("demo", "gw", nn("", "tls")),
+		gateway("other", "gw", nn("demo", "tls"), nn("", "ca")),
+	}
+
+	kg, err := ComputeKey(gatewaySecretsKey, true, crcs, nil, crgs, nil, objects)
+	if err != nil {
+		t.Fatalf("ComputeKey() error = %v", err)
+	}
+	// Cluster-scoped targets have no namespace, whatever the reference
+	// says, and need no ReferenceGrant.
+	want := []store.Edge{
+		{Target: nn("", "tls"), Subjects: []v1a1.Subject{user}, Sources: []types.NamespacedName{nn("demo", "gw"), nn("other", "gw")}},
+		{Target: nn("", "ca"), Subjects: []v1a1.Subject{user}, Sources: []types.NamespacedName{nn("other", "gw")}},
+	}
+	if diff := cmp.Diff(want, kg.Edges); diff != "" {
+		t.Errorf("ComputeKey() edges mismatch (-want +got):\n%s", diff)
+	}
+	if len(kg.Provenance.Ungranted) != 0 {
+		t.Errorf("ComputeKey() expected no ungranted references, got %v", kg.Provenance.Ungranted)
+	}
+}
+
+func TestRESTMapperScope(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "IngressClass"}, meta.RESTScopeRoot)
+	scope := RESTMapperScope(mapper)
+
+	tests := []struct {
+		gr      schema.GroupResource
+		want    bool
+		wantErr bool
+	}{
+		{gr: schema.GroupResource{Resource: "secrets"}, want: false},
+		{gr: schema.GroupResource{Group: "networking.k8s.io", Resource: "ingressclasses"}, want: true},
+		{gr: schema.GroupResource{Group: "example.com", Resource: "widgets"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := scope(tt.gr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("scope(%s) error = %v, wantErr %v", tt.gr, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("scope(%s) = %v, want %v", tt.gr, got, tt.want)
+		}
+	}
+}
+
+func TestCompute(t *testing.T) {
+	crcs := []v1a1.ClusterReferenceConsumer{consumer("alice", user, gateways, secrets, "tls-serving")}
+	crgs := []v1a1.ClusterReferenceGrant{
+		grant("gateways", gateways, secrets, certificateRefs, "tls-serving"),
+		grant("gateway-configmaps", gateways, v1a1.GroupResource{Resource: "configmaps"}, "$.spec.infrastructure.parametersRef", "parameters"),
+	}
+	objects := map[schema.GroupResource][]unstructured.Unstructured{
+		{Group: gateways.Group, Resource: gateways.Resource}: {gateway("demo", "gw", nn("", "tls"))},
+	}
+
+	graphs, err := Compute(crcs, nil, crgs, nil, nil, objects, nil, nil, DefaultMaxChainDepth)
+	if err != nil {
+		t.Fatalf("Compute() error = %v", err)
+	}
+	keys := []string{}
+	for _, kg := range graphs {
+		keys = append(keys, kg.Key)
+	}
+	want := []string{"gateway.networking.k8s.io/gateways;/configmaps;parameters", gatewaySecretsKey}
+	if diff := cmp.Diff(want, keys); diff != "" {
+		t.Fatalf("Compute() keys mismatch (-want +got):\n%s", diff)
+	}
+	if len(graphs[0].Edges) != 0 {
+		t.Errorf("Compute() expected no edges for %s, got %v", graphs[0].Key, graphs[0].Edges)
+	}
+	if len(graphs[1].Edges) != 1 {
+		t.Errorf("Compute() expected one edge for %s, got %v", graphs[1].Key, graphs[1].Edges)
+	}
+}
+
+func TestFromResource(t *testing.T) {
+	crgs := []v1a1.ClusterReferenceGrant{grant("gateways", gateways, secrets, certificateRefs, "tls-serving")}
+
+	gvr, ok := FromResource(gatewaySecretsKey, crgs)
+	want := schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"}
+	if !ok || gvr != want {
+		t.Errorf("FromResource() = %v, %v, want %v, true", gvr, ok, want)
+	}
+	if _, ok := FromResource("gateway.networking.k8s.io/gateways;/secrets;other", crgs); ok {
+		t.Errorf("FromResource() expected no reference path for another purpose")
+	}
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+var secretResource = schema.GroupResource{Resource: "secrets"}
+
+// TargetFunc looks up a target of a resource. It returns nil if the target
+// doesn't exist.
+type TargetFunc func(gr schema.GroupResource, target types.NamespacedName) (*unstructured.Unstructured, error)
+
+// StripTarget returns the parts of an unstructured target that exclusions
+// match on: its metadata, less managed fields, and its type for Secrets. It
+// is a cache transform, so that informers of targets don't hold their
+// content, such as the data of Secrets. Other objects are returned as is.
+func StripTarget(obj interface{}) (interface{}, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return obj, nil
+	}
+	stripped := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	for _, field := range []string{"apiVersion", "kind", "metadata", "type"} {
+		if value, ok := u.Object[field]; ok {
+			stripped.Object[field] = value
+		}
+	}
+	stripped.SetManagedFields(nil)
+	return stripped, nil
+}
+
+// NeedsTargets reports whether policies select targets by their labels or
+// Secret types, which can only be matched by looking up the targets.
+func NeedsTargets(policies []v1a1.ReferencePolicy) bool {
+	for _, policy := range policies {
+		for _, exclusion := range policy.Exclusions {
+			if exclusion.LabelSelector != nil || len(exclusion.SecretTypes) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Exclude drops the edges of kg to the targets excluded by policies, and
+// records them in the provenance of kg along with the policies excluding
+// them. Targets are only looked up with target for exclusions selecting them
+// by labels or Secret types. Targets that don't exist, or can't be looked up
+// because target is nil or fails, match those exclusions, as they may be
+// created with matching labels before the key is recomputed. Invalid name
+// patterns and label selectors match every target too, see ValidatePolicy,
+// so that a bad policy fails closed rather than holding back the graph.
+func Exclude(kg KeyGraph, policies []v1a1.ReferencePolicy, target TargetFunc) KeyGraph {
+	if len(policies) == 0 || len(kg.Edges) == 0 {
+		return kg
+	}
+	to := ToResource(kg.Key)
+	edges := make([]store.Edge, 0, len(kg.Edges))
+	for _, edge := range kg.Edges {
+		m := targetMatcher{to: to, target: edge.Target, lookup: target}
+		var excludedBy []string
+		for _, policy := range policies {
+			for _, exclusion := range policy.Exclusions {
+				if m.matches(exclusion) {
+					excludedBy = append(excludedBy, policy.Name)
+					break
+				}
+			}
+		}
+		if len(excludedBy) == 0 {
+			edges = append(edges, edge)
+			continue
+		}
+		if kg.Provenance.Blocked == nil {
+			kg.Provenance.Blocked = map[types.NamespacedName][]string{}
+		}
+		kg.Provenance.Blocked[edge.Target] = excludedBy
+	}
+	kg.Edges = edges
+	return kg
+}
+
+// ValidatePolicy returns an error if an exclusion of policy has an invalid
+// name pattern or label selector. Exclude treats them as matching every
+// target.
+func ValidatePolicy(policy v1a1.ReferencePolicy) error {
+	var errs []error
+	for i, exclusion := range policy.Exclusions {
+		for _, pattern := range exclusion.Names {
+			if _, err := path.Match(pattern, ""); err != nil {
+				errs = append(errs, fmt.Errorf("exclusion %d: invalid name pattern %q: %w", i, pattern, err))
+			}
+		}
+		if exclusion.LabelSelector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(exclusion.LabelSelector); err != nil {
+				errs = append(errs, fmt.Errorf("exclusion %d: invalid label selector: %w", i, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// targetMatcher matches a target against exclusions, looking it up at most
+// once.
+type targetMatcher struct {
+	to     schema.GroupResource
+	target types.NamespacedName
+	lookup TargetFunc
+
+	looked bool
+	object *unstructured.Unstructured
+}
+
+func (m *targetMatcher) matches(exclusion v1a1.ReferenceExclusion) bool {
+	if exclusion.To != nil && m.to != (schema.GroupResource{Group: exclusion.To.Group, Resource: exclusion.To.Resource}) {
+		return false
+	}
+	if len(exclusion.Namespaces) > 0 && !slices.Contains(exclusion.Namespaces, m.target.Namespace) {
+		return false
+	}
+	if len(exclusion.Names) > 0 {
+		matched := false
+		for _, pattern := range exclusion.Names {
+			// Invalid patterns match, so that the exclusion fails closed.
+			if ok, err := path.Match(pattern, m.target.Name); ok || err != nil {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(exclusion.SecretTypes) > 0 && m.to != secretResource {
+		return false
+	}
+	if exclusion.LabelSelector == nil && len(exclusion.SecretTypes) == 0 {
+		return true
+	}
+
+	selector := labels.Everything()
+	if exclusion.LabelSelector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(exclusion.LabelSelector); err != nil {
+			return true
+		}
+	}
+	object := m.get()
+	if object == nil {
+		return true
+	}
+	if !selector.Matches(labels.Set(object.GetLabels())) {
+		return false
+	}
+	if len(exclusion.SecretTypes) > 0 {
+		secretType, _, _ := unstructured.NestedString(object.Object, "type")
+		if secretType == "" {
+			secretType = "Opaque"
+		}
+		return slices.Contains(exclusion.SecretTypes, secretType)
+	}
+	return true
+}
+
+// get looks up the target, returning nil if it doesn't exist or the lookup
+// fails, so that it is excluded rather than granted on errors.
+func (m *targetMatcher) get() *unstructured.Unstructured {
+	if m.looked {
+		return m.object
+	}
+	m.looked = true
+	if m.lookup == nil {
+		return nil
+	}
+	if object, err := m.lookup(m.to, m.target); err == nil {
+		m.object = object
+	}
+	return m.object
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+func policy(name string, exclusions ...v1a1.ReferenceExclusion) v1a1.ReferencePolicy {
+	return v1a1.ReferencePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Exclusions: exclusions,
+	}
+}
+
+func secret(namespace, name, secretType string, labels map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"type":       secretType,
+	}}
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetLabels(labels)
+	return obj
+}
+
+func TestExclude(t *testing.T) {
+	objects := map[types.NamespacedName]*unstructured.Unstructured{
+		nn("kube-system", "sa-token"): secret("kube-system", "sa-token", "kubernetes.io/service-account-token", nil),
+		nn("demo", "tls"):             secret("demo", "tls", "kubernetes.io/tls", nil),
+		nn("demo", "db"):              secret("demo", "db", "", map[string]string{"sensitive": "true"}),
+	}
+	target := func(gr schema.GroupResource, target types.NamespacedName) (*unstructured.Unstructured, error) {
+		return objects[target], nil
+	}
+	kg := KeyGraph{
+		Key: gatewaySecretsKey,
+		Edges: []store.Edge{
+			{Target: nn("kube-system", "sa-token"), Subjects: []v1a1.Subject{user}},
+			{Target: nn("demo", "tls"), Subjects: []v1a1.Subject{user}},
+			{Target: nn("demo", "db"), Subjects: []v1a1.Subject{user}},
+			{Target: nn("demo", "missing"), Subjects: []v1a1.Subject{user}},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		policies    []v1a1.ReferencePolicy
+		wantTargets []types.NamespacedName
+		wantBlocked map[types.NamespacedName][]string
+	}{
+		{
+			name:        "no policies",
+			wantTargets: []types.NamespacedName{nn("kube-system", "sa-token"), nn("demo", "tls"), nn("demo", "db"), nn("demo", "missing")},
+		},
+		{
+			name:        "namespaces",
+			policies:    []v1a1.ReferencePolicy{policy("system", v1a1.ReferenceExclusion{Namespaces: []string{"kube-system"}})},
+			wantTargets: []types.NamespacedName{nn("demo", "tls"), nn("demo", "db"), nn("demo", "missing")},
+			wantBlocked: map[types.NamespacedName][]string{nn("kube-system", "sa-token"): {"system"}},
+		},
+		{
+			name: "name patterns of other resources",
+			policies: []v1a1.ReferencePolicy{policy("configmaps", v1a1.ReferenceExclusion{
+				To:    &v1a1.GroupResource{Resource: "configmaps"},
+				Names: []string{"*"},
+			})},
+			wantTargets: []types.NamespacedName{nn("kube-system", "sa-token"), nn("demo", "tls"), nn("demo", "db"), nn("demo", "missing")},
+		},
+		{
+			name:        "secret types",
+			policies:    []v1a1.ReferencePolicy{policy("tokens", v1a1.ReferenceExclusion{SecretTypes: []string{"kubernetes.io/service-account-token", "Opaque"}})},
+			wantTargets: []types.NamespacedName{nn("demo", "tls")},
+			wantBlocked: map[types.NamespacedName][]string{
+				nn("kube-system", "sa-token"): {"tokens"},
+				nn("demo", "db"):              {"tokens"},
+				nn("demo", "missing"):         {"tokens"},
+			},
+		},
+		{
+			name: "label selector and names",
+			policies: []v1a1.ReferencePolicy{
+				policy("sensitive", v1a1.ReferenceExclusion{
+					Names:         []string{"d*"},
+					LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"sensitive": "true"}},
+				}),
+				policy("system", v1a1.ReferenceExclusion{Namespaces: []string{"demo"}, Names: []string{"db"}}),
+			},
+			wantTargets: []types.NamespacedName{nn("kube-system", "sa-token"), nn("demo", "tls"), nn("demo", "missing")},
+			wantBlocked: map[types.NamespacedName][]string{nn("demo", "db"): {"sensitive", "system"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Exclude(kg, tt.policies, target)
+			targets := []types.NamespacedName{}
+			for _, edge := range got.Edges {
+				targets = append(targets, edge.Target)
+			}
+			if diff := cmp.Diff(tt.wantTargets, targets); diff != "" {
+				t.Errorf("Exclude() targets mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantBlocked, got.Provenance.Blocked); diff != "" {
+				t.Errorf("Exclude() blocked mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExcludeInvalid(t *testing.T) {
+	kg := KeyGraph{
+		Key: gatewaySecretsKey,
+		Edges: []store.Edge{
+			{Target: nn("demo", "tls"), Subjects: []v1a1.Subject{user}},
+			{Target: nn("other", "tls"), Subjects: []v1a1.Subject{user}},
+		},
+	}
+	invalidSelector := &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "sensitive", Operator: metav1.LabelSelectorOpIn}}}
+	tests := []struct {
+		name      string
+		exclusion v1a1.ReferenceExclusion
+	}{
+		{name: "name pattern", exclusion: v1a1.ReferenceExclusion{Namespaces: []string{"demo"}, Names: []string{"["}}},
+		{name: "label selector", exclusion: v1a1.ReferenceExclusion{Namespaces: []string{"demo"}, LabelSelector: invalidSelector}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies := []v1a1.ReferencePolicy{policy("invalid", tt.exclusion)}
+			if err := ValidatePolicy(policies[0]); err == nil {
+				t.Errorf("ValidatePolicy() expected an error")
+			}
+			// The invalid field matches every target the others match.
+			got := Exclude(kg, policies, nil)
+			if len(got.Edges) != 1 || got.Edges[0].Target != nn("other", "tls") {
+				t.Errorf("Exclude() edges = %v, want only other/tls", got.Edges)
+			}
+			wantBlocked := map[types.NamespacedName][]string{nn("demo", "tls"): {"invalid"}}
+			if diff := cmp.Diff(wantBlocked, got.Provenance.Blocked); diff != "" {
+				t.Errorf("Exclude() blocked mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	valid := policy("valid",
+		v1a1.ReferenceExclusion{Names: []string{"*-token-*", "[a-z]*"}},
+		v1a1.ReferenceExclusion{LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"sensitive": "true"}}},
+	)
+	if err := ValidatePolicy(valid); err != nil {
+		t.Errorf("ValidatePolicy() error = %v", err)
+	}
+}
+
+func TestExcludeLookupError(t *testing.T) {
+	target := func(gr schema.GroupResource, target types.NamespacedName) (*unstructured.Unstructured, error) {
+		if target.Namespace == "unreachable" {
+			return nil, errors.New("timed out waiting for the informer")
+		}
+		return secret(target.Namespace, target.Name, "kubernetes.io/tls", nil), nil
+	}
+	kg := KeyGraph{
+		Key: gatewaySecretsKey,
+		Edges: []store.Edge{
+			{Target: nn("demo", "tls"), Subjects: []v1a1.Subject{user}},
+			{Target: nn("unreachable", "tls"), Subjects: []v1a1.Subject{user}},
+		},
+	}
+	policies := []v1a1.ReferencePolicy{
+		policy("tokens", v1a1.ReferenceExclusion{SecretTypes: []string{"kubernetes.io/service-account-token"}}),
+		policy("system", v1a1.ReferenceExclusion{Namespaces: []string{"kube-system"}}),
+	}
+
+	got := Exclude(kg, policies, target)
+	targets := []types.NamespacedName{}
+	for _, edge := range got.Edges {
+		targets = append(targets, edge.Target)
+	}
+	if diff := cmp.Diff([]types.NamespacedName{nn("demo", "tls")}, targets); diff != "" {
+		t.Errorf("Exclude() targets mismatch (-want +got):\n%s", diff)
+	}
+	wantBlocked := map[types.NamespacedName][]string{nn("unreachable", "tls"): {"tokens"}}
+	if diff := cmp.Diff(wantBlocked, got.Provenance.Blocked); diff != "" {
+		t.Errorf("Exclude() blocked mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestStripTarget(t *testing.T) {
+	obj := secret("demo", "tls", "kubernetes.io/tls", map[string]string{"sensitive": "true"})
+	obj.Object["data"] = map[string]interface{}{"tls.key": "c2VjcmV0"}
+	obj.Object["stringData"] = map[string]interface{}{"password": "secret"}
+	obj.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "kubectl"}})
+
+	got, err := StripTarget(obj)
+	if err != nil {
+		t.Fatalf("StripTarget() error = %v", err)
+	}
+	want := secret("demo", "tls", "kubernetes.io/tls", map[string]string{"sensitive": "true"})
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("StripTarget() mismatch (-want +got):\n%s", diff)
+	}
+}
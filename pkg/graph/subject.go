@@ -0,0 +1,156 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+)
+
+// SubjectTemplateData is what the name and namespace of the subjects of
+// ClusterReferenceConsumers are rendered with when they are templates.
+type SubjectTemplateData struct {
+	// FromNamespace is the namespace of the From object referencing a
+	// target.
+	FromNamespace string
+}
+
+// consumerSubject is the subject of a ClusterReferenceConsumer or
+// ReferenceConsumer for a key.
+type consumerSubject struct {
+	// consumer is the name of the ClusterReferenceConsumer, or the
+	// namespace/name of the ReferenceConsumer.
+	consumer     string
+	subject      v1a1.Subject
+	subresources []string
+	// name and namespace are only set for templated subjects.
+	name      *template.Template
+	namespace *template.Template
+	// fromNamespace, when set, is the only namespace of From objects the
+	// subject is a consumer of references from.
+	fromNamespace string
+}
+
+func newConsumerSubject(crc v1a1.ClusterReferenceConsumer, ref v1a1.ConsumerReference) (consumerSubject, error) {
+	cs := consumerSubject{consumer: crc.Name, subject: crc.Subject, subresources: ref.Subresources}
+	var err error
+	if isTemplate(crc.Subject.Name) {
+		if cs.name, err = parseSubjectTemplate(crc.Subject.Name); err != nil {
+			return cs, fmt.Errorf("invalid subject name of ClusterReferenceConsumer %s: %w", crc.Name, err)
+		}
+	}
+	if isTemplate(crc.Subject.Namespace) {
+		if cs.namespace, err = parseSubjectTemplate(crc.Subject.Namespace); err != nil {
+			return cs, fmt.Errorf("invalid subject namespace of ClusterReferenceConsumer %s: %w", crc.Name, err)
+		}
+	}
+	if !cs.templated() {
+		cs.subject = normalizeSubject(cs.subject)
+	}
+	return cs, nil
+}
+
+// ValidateConsumer returns an error if the subject of crc is an invalid
+// template, or can't be rendered. Such consumers are skipped by ComputeKey.
+func ValidateConsumer(crc v1a1.ClusterReferenceConsumer) error {
+	cs, err := newConsumerSubject(crc, v1a1.ConsumerReference{})
+	if err != nil {
+		return err
+	}
+	if cs.templated() {
+		_, _, err = cs.render("default")
+	}
+	return err
+}
+
+// newReferenceConsumerSubject returns the subject of a ReferenceConsumer,
+// which is never authorized for subresources.
+func newReferenceConsumerSubject(rc v1a1.ReferenceConsumer) consumerSubject {
+	return consumerSubject{
+		consumer:      fmt.Sprintf("%s/%s", rc.Namespace, rc.Name),
+		subject:       normalizeSubject(v1a1.Subject{Kind: "ServiceAccount", Namespace: rc.Namespace, Name: rc.ServiceAccountName}),
+		fromNamespace: rc.Namespace,
+	}
+}
+
+// templated reports whether the subject is rendered from the From objects.
+func (cs consumerSubject) templated() bool {
+	return cs.name != nil || cs.namespace != nil
+}
+
+// perEdge reports whether the subject depends on the From objects.
+func (cs consumerSubject) perEdge() bool {
+	return cs.templated() || cs.fromNamespace != ""
+}
+
+// render returns the subject for references from fromNamespace. It returns
+// false if the subject is not a consumer of references from fromNamespace,
+// or renders to an empty name, or namespace for ServiceAccounts.
+func (cs consumerSubject) render(fromNamespace string) (v1a1.Subject, bool, error) {
+	if cs.fromNamespace != "" && cs.fromNamespace != fromNamespace {
+		return cs.subject, false, nil
+	}
+	subject := cs.subject
+	data := SubjectTemplateData{FromNamespace: fromNamespace}
+	var err error
+	if cs.name != nil {
+		if subject.Name, err = execute(cs.name, data); err != nil {
+			return subject, false, fmt.Errorf("failed to render the subject name of ClusterReferenceConsumer %s: %w", cs.consumer, err)
+		}
+	}
+	if cs.namespace != nil {
+		if subject.Namespace, err = execute(cs.namespace, data); err != nil {
+			return subject, false, fmt.Errorf("failed to render the subject namespace of ClusterReferenceConsumer %s: %w", cs.consumer, err)
+		}
+	}
+	if subject.Name == "" || (subject.Kind == "ServiceAccount" && subject.Namespace == "") {
+		return subject, false, nil
+	}
+	return normalizeSubject(subject), true, nil
+}
+
+// normalizeSubject returns ServiceAccounts as the users they authenticate
+// as, which is what SubjectAccessReviews carry.
+func normalizeSubject(subject v1a1.Subject) v1a1.Subject {
+	if subject.Kind == "ServiceAccount" {
+		// subject.Name = apiserverserviceaccount.MakeUsername(subject.Namespace, subject.Name)
+		return v1a1.Subject{
+			Kind: "User",
+			Name: fmt.Sprintf("system:serviceaccount:%s:%s", subject.Namespace, subject.Name),
+		}
+	}
+	return subject
+}
+
+func isTemplate(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+func parseSubjectTemplate(text string) (*template.Template, error) {
+	return template.New("subject").Option("missingkey=error").Parse(text)
+}
+
+func execute(t *template.Template, data SubjectTemplateData) (string, error) {
+	var out strings.Builder
+	if err := t.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+)
+
+// ReferenceGrantState returns whether rg allows references at now.
+func ReferenceGrantState(rg v1a1.ReferenceGrant, now time.Time) v1a1.ReferenceGrantState {
+	switch {
+	case rg.NotBefore != nil && now.Before(rg.NotBefore.Time):
+		return v1a1.ReferenceGrantPending
+	case rg.ExpiresAt != nil && !now.Before(rg.ExpiresAt.Time):
+		return v1a1.ReferenceGrantExpired
+	default:
+		return v1a1.ReferenceGrantActive
+	}
+}
+
+// ActiveReferenceGrants returns the ReferenceGrants of rgs that allow
+// references at now, along with the earliest time after now at which one of
+// the ReferenceGrants of keys starts or stops allowing references. That time
+// is zero if none of them ever does.
+func ActiveReferenceGrants(rgs []v1a1.ReferenceGrant, keys sets.Set[string], now time.Time) ([]v1a1.ReferenceGrant, time.Time) {
+	active := make([]v1a1.ReferenceGrant, 0, len(rgs))
+	var next time.Time
+	for _, rg := range rgs {
+		if ReferenceGrantState(rg, now) == v1a1.ReferenceGrantActive {
+			active = append(active, rg)
+		}
+		key := fmt.Sprintf("%s/%s;%s/%s;%s", rg.From.Group, rg.From.Resource, rg.To.Group, rg.To.Resource, rg.For)
+		if !keys.Has(key) {
+			continue
+		}
+		for _, t := range []*time.Time{timeOf(rg.NotBefore), timeOf(rg.ExpiresAt)} {
+			if t != nil && t.After(now) && (next.IsZero() || t.Before(next)) {
+				next = *t
+			}
+		}
+	}
+	return active, next
+}
+
+func timeOf(t *metav1.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+)
+
+func withWindow(rg v1a1.ReferenceGrant, notBefore, expiresAt time.Time) v1a1.ReferenceGrant {
+	if !notBefore.IsZero() {
+		rg.NotBefore = &metav1.Time{Time: notBefore}
+	}
+	if !expiresAt.IsZero() {
+		rg.ExpiresAt = &metav1.Time{Time: expiresAt}
+	}
+	return rg
+}
+
+func TestActiveReferenceGrants(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	rgs := []v1a1.ReferenceGrant{
+		referenceGrant("demo", "forever", "other", "tls"),
+		withWindow(referenceGrant("demo", "migration", "other", "tls"), now.Add(-time.Hour), now.Add(2*time.Hour)),
+		withWindow(referenceGrant("demo", "pending", "other", "tls"), now.Add(time.Hour), time.Time{}),
+		withWindow(referenceGrant("demo", "expired", "other", "tls"), time.Time{}, now),
+	}
+
+	tests := []struct {
+		name       string
+		keys       sets.Set[string]
+		wantActive []string
+		wantNext   time.Time
+	}{
+		{
+			name:       "next transition of the keys",
+			keys:       sets.New(gatewaySecretsKey),
+			wantActive: []string{"forever", "migration"},
+			wantNext:   now.Add(time.Hour),
+		},
+		{
+			name:       "other keys",
+			keys:       sets.New("gateway.networking.k8s.io/gateways;/configmaps;parameters"),
+			wantActive: []string{"forever", "migration"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			active, next := ActiveReferenceGrants(rgs, tt.keys, now)
+			names := []string{}
+			for _, rg := range active {
+				names = append(names, rg.Name)
+			}
+			if diff := cmp.Diff(tt.wantActive, names); diff != "" {
+				t.Errorf("ActiveReferenceGrants() mismatch (-want +got):\n%s", diff)
+			}
+			if !next.Equal(tt.wantNext) {
+				t.Errorf("ActiveReferenceGrants() next = %v, want %v", next, tt.wantNext)
+			}
+		})
+	}
+
+	wantStates := map[string]v1a1.ReferenceGrantState{
+		"forever":   v1a1.ReferenceGrantActive,
+		"migration": v1a1.ReferenceGrantActive,
+		"pending":   v1a1.ReferenceGrantPending,
+		"expired":   v1a1.ReferenceGrantExpired,
+	}
+	for _, rg := range rgs {
+		if got := ReferenceGrantState(rg, now); got != wantStates[rg.Name] {
+			t.Errorf("ReferenceGrantState(%s) = %s, want %s", rg.Name, got, wantStates[rg.Name])
+		}
+	}
+}
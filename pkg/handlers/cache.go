@@ -0,0 +1,108 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/utils/lru"
+	"sigs.k8s.io/referencegrant-poc/pkg/metrics"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+// decisionKey identifies the requests a decision can be reused for.
+type decisionKey struct {
+	user        string
+	groups      string
+	verb        string
+	group       string
+	resource    string
+	subresource string
+	namespace   string
+	name        string
+	// fieldSelector limits list and watch requests to names.
+	fieldSelector string
+	purpose       string
+}
+
+func newDecisionKey(sar authorizationv1.SubjectAccessReview) decisionKey {
+	attrs := sar.Spec.ResourceAttributes
+	key := decisionKey{
+		user:        sar.Spec.User,
+		groups:      strings.Join(sar.Spec.Groups, "\n"),
+		verb:        attrs.Verb,
+		group:       attrs.Group,
+		resource:    attrs.Resource,
+		subresource: attrs.Subresource,
+		namespace:   attrs.Namespace,
+		name:        attrs.Name,
+		purpose:     strings.Join(sar.Spec.Extra[store.ExtraPurposeKey], "\n"),
+	}
+	if attrs.FieldSelector != nil {
+		key.fieldSelector = fmt.Sprintf("%+v", *attrs.FieldSelector)
+	}
+	return key
+}
+
+// decisionCache caches the decisions of the store for the generation of its
+// graph. Any change of the graph invalidates all decisions. It is safe for
+// concurrent use.
+type decisionCache struct {
+	mutex      sync.Mutex
+	generation int64
+	decisions  *lru.Cache
+}
+
+func newDecisionCache(size int) *decisionCache {
+	return &decisionCache{decisions: lru.New(size)}
+}
+
+// get returns the decision cached for key, if it was made on generation.
+func (c *decisionCache) get(key decisionKey, generation int64) (store.Decision, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.invalidate(generation)
+	value, ok := c.decisions.Get(key)
+	if !ok {
+		metrics.DecisionCacheLookups.WithLabelValues(metrics.CacheMiss).Inc()
+		return store.Decision{}, false
+	}
+	metrics.DecisionCacheLookups.WithLabelValues(metrics.CacheHit).Inc()
+	return value.(store.Decision), true
+}
+
+// add caches decision for key, unless it was made on an older generation
+// than the cached decisions.
+func (c *decisionCache) add(key decisionKey, decision store.Decision) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.invalidate(decision.Generation)
+	if decision.Generation == c.generation {
+		c.decisions.Add(key, decision)
+	}
+}
+
+// invalidate drops the cached decisions if generation is newer.
+func (c *decisionCache) invalidate(generation int64) {
+	if generation > c.generation {
+		c.decisions.Clear()
+		c.generation = generation
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handlers
+
+import (
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+func getSecretSAR(user, name string) authorizationv1.SubjectAccessReview {
+	return authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User: user,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Verb:      "get",
+				Resource:  "secrets",
+				Namespace: "demo",
+				Name:      name,
+			},
+		},
+	}
+}
+
+func TestDecisionCache(t *testing.T) {
+	cache := newDecisionCache(2)
+	key := newDecisionKey(getSecretSAR("alice", "tls"))
+
+	if _, ok := cache.get(key, 1); ok {
+		t.Fatalf("expected a miss on an empty cache")
+	}
+	cache.add(key, store.Decision{Allowed: true, Generation: 1})
+	if got, ok := cache.get(key, 1); !ok || !got.Allowed {
+		t.Fatalf("expected the cached decision, got %v, %v", got, ok)
+	}
+
+	other := getSecretSAR("alice", "tls")
+	other.Spec.Groups = []string{"admins"}
+	if _, ok := cache.get(newDecisionKey(other), 1); ok {
+		t.Errorf("expected a miss for other groups")
+	}
+	list := getSecretSAR("alice", "")
+	list.Spec.ResourceAttributes.FieldSelector = &authorizationv1.FieldSelectorAttributes{
+		Requirements: []metav1.FieldSelectorRequirement{{Key: "metadata.name", Operator: "In", Values: []string{"tls"}}},
+	}
+	if _, ok := cache.get(newDecisionKey(list), 1); ok {
+		t.Errorf("expected a miss for a field selector")
+	}
+
+	// A change of the graph invalidates the decision.
+	if _, ok := cache.get(key, 2); ok {
+		t.Fatalf("expected a miss on a newer generation")
+	}
+	// Decisions made on an older graph aren't cached.
+	cache.add(key, store.Decision{Allowed: true, Generation: 1})
+	if _, ok := cache.get(key, 2); ok {
+		t.Fatalf("expected a decision of an older generation not to be cached")
+	}
+	cache.add(key, store.Decision{Allowed: false, Generation: 2})
+	if got, ok := cache.get(key, 2); !ok || got.Allowed {
+		t.Fatalf("expected the decision of the newer generation, got %v, %v", got, ok)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+// usersAuthorizer allows its users to access anything.
+type usersAuthorizer struct {
+	users sets.Set[string]
+}
+
+func (u usersAuthorizer) CheckAuthz(sar authorizationv1.SubjectAccessReview) (store.Decision, error) {
+	return store.Decision{Allowed: u.users.Has(sar.Spec.User), Generation: 1}, nil
+}
+
+func (u usersAuthorizer) Generation() int64 {
+	return 1
+}
+
+// TestConformance replays the SubjectAccessReviews of testdata/conformance,
+// in the format kube-apiservers send them for each subjectAccessReviewVersion,
+// and expects the handler to answer with the matching responses.
+func TestConformance(t *testing.T) {
+	requests, err := filepath.Glob(filepath.Join("testdata", "conformance", "*.request.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) == 0 {
+		t.Fatal("no conformance payloads")
+	}
+	handler := AuthzHandler(usersAuthorizer{users: sets.New("system:serviceaccount:demo:demo-controller")}, AuthzOptions{})
+
+	for _, request := range requests {
+		name := strings.TrimSuffix(filepath.Base(request), ".request.json")
+		t.Run(name, func(t *testing.T) {
+			body, err := os.ReadFile(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response, err := os.ReadFile(strings.Replace(request, ".request.json", ".response.json", 1))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/authorize", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+			}
+
+			var want, got interface{}
+			if err := json.Unmarshal(response, &want); err != nil {
+				t.Fatalf("failed to unmarshal expected response: %v", err)
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("response mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestDecodeVersions expects the same review sent in each version to decode
+// to the same v1 review.
+func TestDecodeVersions(t *testing.T) {
+	decode := func(name string) authorizationv1.SubjectAccessReview {
+		t.Helper()
+		body, err := os.ReadFile(filepath.Join("testdata", "conformance", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sar, _, err := decodeSubjectAccessReview(body)
+		if err != nil {
+			t.Fatalf("failed to decode %s: %v", name, err)
+		}
+		return sar
+	}
+	v1 := decode("v1-get-secret.request.json")
+	v1beta1 := decode("v1beta1-get-secret.request.json")
+	if diff := cmp.Diff(v1.Spec, v1beta1.Spec); diff != "" {
+		t.Errorf("v1beta1 spec mismatch (-v1 +v1beta1):\n%s", diff)
+	}
+	if len(v1beta1.Spec.Groups) == 0 || len(v1beta1.Spec.Extra) == 0 {
+		t.Errorf("expected the groups and extra of the v1beta1 review to be decoded, got %+v", v1beta1.Spec)
+	}
+}
@@ -18,70 +18,246 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/audit"
+	"sigs.k8s.io/referencegrant-poc/pkg/metrics"
 	"sigs.k8s.io/referencegrant-poc/pkg/store"
 )
 
-func sendNotAuthorizedResponse(w http.ResponseWriter, reason string) {
-	resp := authorizationv1.SubjectAccessReview{
-		Status: authorizationv1.SubjectAccessReviewStatus{
-			Allowed: false,
-			Reason:  reason,
-		},
+// MaxRequestBytes is the default limit of the size of SubjectAccessReview
+// requests.
+const MaxRequestBytes = 1 << 20
+
+// sendNoOpinionResponse answers a SubjectAccessReview without an opinion, so
+// that kube-apiservers ask the next authorizer, in version gv.
+func sendNoOpinionResponse(w http.ResponseWriter, gv schema.GroupVersion, reason string) {
+	status := authorizationv1.SubjectAccessReviewStatus{
+		Allowed: false,
+		Reason:  reason,
+	}
+	recordDecision(status, nil, "")
+	writeSubjectAccessReview(w, gv, status)
+}
+
+// writeSubjectAccessReview writes a SubjectAccessReview response with status,
+// in version gv.
+func writeSubjectAccessReview(w http.ResponseWriter, gv schema.GroupVersion, status authorizationv1.SubjectAccessReviewStatus) {
+	responseBytes, err := encodeSubjectAccessReview(&authorizationv1.SubjectAccessReview{Status: status}, gv)
+	if err != nil {
+		log.Printf("Failed to encode SubjectAccessReview: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-	responseBytes, _ := json.Marshal(resp)
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(responseBytes)
+}
 
+// Authorizer decides SubjectAccessReviews, e.g. the *store.AuthStore.
+type Authorizer interface {
+	CheckAuthz(sar authorizationv1.SubjectAccessReview) (store.Decision, error)
+	// Generation is the generation of the graph decisions are made on.
+	Generation() int64
 }
 
-func AuthzHandler(store *store.AuthStore) http.HandlerFunc {
+// AuthzOptions configure the AuthzHandler.
+type AuthzOptions struct {
+	// AuditLogger is written every decision to. It may be nil.
+	AuditLogger *audit.Logger
+	// ProtectedResources limits the handler to these resources, formatted
+	// as "resource" for the core group or "resource.group". The handler has
+	// no opinion on other resources. When empty, all resources are looked up.
+	ProtectedResources []string
+	// DecisionCacheSize is the number of decisions cached until the graph
+	// changes. Zero disables the cache.
+	DecisionCacheSize int
+	// GenerationInReason adds the generation of the graph to the reason of
+	// decisions, so that it shows in the audit logs of kube-apiservers.
+	GenerationInReason bool
+	// MaxRequestBytes limits the size of requests. Defaults to
+	// MaxRequestBytes.
+	MaxRequestBytes int64
+}
+
+// GenerationHeader is the response header holding the generation of the
+// graph a decision was made on.
+const GenerationHeader = "Reference-Authorization-Generation"
+
+// AuthzHandler serves SubjectAccessReviews from the graph of authorizer.
+// Requests must be POSTed as JSON, in version v1 or v1beta1 of
+// authorization.k8s.io, and are answered in the same version.
+func AuthzHandler(authorizer Authorizer, opts AuthzOptions) http.HandlerFunc {
+	protected := sets.New(opts.ProtectedResources...)
+	var cache *decisionCache
+	if opts.DecisionCacheSize > 0 {
+		cache = newDecisionCache(opts.DecisionCacheSize)
+	}
+	maxBytes := opts.MaxRequestBytes
+	if maxBytes <= 0 {
+		maxBytes = MaxRequestBytes
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		content, err := ioutil.ReadAll(r.Body)
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+			return
+		}
+		if contentType := r.Header.Get("Content-Type"); contentType != "" {
+			mediaType, _, err := mime.ParseMediaType(contentType)
+			if err != nil || mediaType != "application/json" {
+				http.Error(w, fmt.Sprintf("unsupported Content-Type %q, expected application/json", contentType), http.StatusUnsupportedMediaType)
+				return
+			}
+		}
+		content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
 		if err != nil {
 			log.Printf("Error in Read of request body : %s", err)
-			sendNotAuthorizedResponse(w, "Error in Read of request body")
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, fmt.Sprintf("request body is larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Error in Read of request body", http.StatusBadRequest)
+			return
 		}
-		rawContent := json.RawMessage(string(content))
-
-		sar := authorizationv1.SubjectAccessReview{}
-		err = json.Unmarshal(content, &sar)
+		sar, gv, err := decodeSubjectAccessReview(content)
 		if err != nil {
-			log.Printf("Failed to unmarshal: %v", err)
-			sendNotAuthorizedResponse(w, err.Error())
+			log.Printf("Failed to decode SubjectAccessReview: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		if sar.Spec.ResourceAttributes == nil {
-			sendNotAuthorizedResponse(w, "Not authorizing nonResourceAttributes")
+			sendNoOpinionResponse(w, gv, "No opinion on non-resource requests")
 			return
 		}
-		var print bool
-		if sar.Spec.ResourceAttributes.Resource == "secrets" && strings.HasPrefix(sar.Spec.ResourceAttributes.Name, "demo") {
-			print = true
-			log.Printf("Request body : %s\n", rawContent)
+		if protected.Len() > 0 && !protected.Has(resourceName(sar.Spec.ResourceAttributes)) {
+			sendNoOpinionResponse(w, gv, "No opinion on unprotected resources")
+			return
 		}
-
-		allowed, _ := store.CheckAuthz(sar)
-		sarResponseStatus := authorizationv1.SubjectAccessReviewStatus{
-			Allowed: allowed,
+		start := time.Now()
+		var decision store.Decision
+		if cache == nil {
+			decision, err = authorizer.CheckAuthz(sar)
+		} else {
+			key := newDecisionKey(sar)
+			var cached bool
+			if decision, cached = cache.get(key, authorizer.Generation()); !cached {
+				decision, err = authorizer.CheckAuthz(sar)
+				if err == nil {
+					cache.add(key, decision)
+				}
+			}
 		}
-		if !allowed {
+		metrics.CheckAuthzDuration.Observe(time.Since(start).Seconds())
+		sarResponseStatus := authorizationv1.SubjectAccessReviewStatus{}
+		switch {
+		case err != nil:
+			log.Printf("Failed to evaluate SubjectAccessReview: %v", err)
+			decision = store.Decision{Generation: decision.Generation}
+			sarResponseStatus.EvaluationError = err.Error()
+		case decision.Allowed:
+			sarResponseStatus.Allowed = true
+		default:
 			sarResponseStatus.Reason = fmt.Sprintf("Referential authorizer did not allow Subject \"%s\" to %s %s/%s/%s/%s ", sar.Spec.User, sar.Spec.ResourceAttributes.Verb, sar.Spec.ResourceAttributes.Group, sar.Spec.ResourceAttributes.Resource, sar.Spec.ResourceAttributes.Namespace, sar.Spec.ResourceAttributes.Name)
 		}
+		if opts.GenerationInReason {
+			sarResponseStatus.Reason = strings.TrimSpace(fmt.Sprintf("%s (graph generation %d)", sarResponseStatus.Reason, decision.Generation))
+		}
 		sar.Status = sarResponseStatus
+		recordDecision(sar.Status, sar.Spec.ResourceAttributes, decision.Purpose)
+		opts.AuditLogger.Log(sar, decisionLabel(sar.Status), decision)
+
+		w.Header().Set(GenerationHeader, strconv.FormatInt(decision.Generation, 10))
+		writeSubjectAccessReview(w, gv, sar.Status)
+	}
+}
+
+// resourceName formats the resource of attrs as "resource" for the core group
+// or "resource.group".
+func resourceName(attrs *authorizationv1.ResourceAttributes) string {
+	if attrs.Group == "" {
+		return attrs.Resource
+	}
+	return attrs.Resource + "." + attrs.Group
+}
+
+// decisionLabel returns the decision of a response. A response that neither
+// allows nor denies has no opinion.
+func decisionLabel(status authorizationv1.SubjectAccessReviewStatus) string {
+	switch {
+	case status.Allowed:
+		return metrics.DecisionAllowed
+	case status.Denied:
+		return metrics.DecisionDenied
+	}
+	return metrics.DecisionNoOpinion
+}
+
+// recordDecision counts the decision of a response.
+func recordDecision(status authorizationv1.SubjectAccessReviewStatus, attrs *authorizationv1.ResourceAttributes, purpose store.Purpose) {
+	decision := decisionLabel(status)
+	var group, resource string
+	if attrs != nil {
+		group, resource = attrs.Group, attrs.Resource
+	}
+	metrics.Decisions.WithLabelValues(decision, group, resource, string(purpose)).Inc()
+}
+
+// ExplainHandler explains why a subject is or isn't authorized to access a
+// target, e.g. /explain?user=...&group=&resource=secrets&namespace=...&name=...
+func ExplainHandler(store *store.AuthStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		for _, param := range []string{"user", "resource", "name"} {
+			if q.Get(param) == "" {
+				http.Error(w, fmt.Sprintf("missing query parameter %q", param), http.StatusBadRequest)
+				return
+			}
+		}
+		explanation := store.Explain(q.Get("user"), q.Get("group"), q.Get("resource"), q.Get("namespace"), q.Get("name"))
+		responseBytes, err := json.Marshal(explanation)
+		if err != nil {
+			log.Printf("Failed to marshal explanation: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(responseBytes)
+	}
+}
 
-		sar.Spec = authorizationv1.SubjectAccessReviewSpec{}
-		responseBytes, _ := json.Marshal(sar)
-		if print {
-			log.Printf("Response body : %s\n", responseBytes)
+// GraphHandler serves the graph and its subject index, optionally filtered by
+// subject, from-to-for key and target namespace, e.g.
+// /graph?user=...&key=...&namespace=...
+func GraphHandler(s *store.AuthStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		filter := store.GraphFilter{Key: q.Get("key"), Namespace: q.Get("namespace")}
+		if user := q.Get("user"); user != "" {
+			filter.Subject = &v1a1.Subject{Kind: "User", Name: user}
+		}
+		responseBytes, err := json.Marshal(s.View(filter))
+		if err != nil {
+			log.Printf("Failed to marshal graph: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		w.Write(responseBytes)
 	}
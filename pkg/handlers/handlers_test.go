@@ -0,0 +1,309 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+// fakeAuthorizer returns decision, or err, and records the reviews it got.
+type fakeAuthorizer struct {
+	decision store.Decision
+	err      error
+	reviews  []authorizationv1.SubjectAccessReview
+}
+
+func (f *fakeAuthorizer) CheckAuthz(sar authorizationv1.SubjectAccessReview) (store.Decision, error) {
+	f.reviews = append(f.reviews, sar)
+	return f.decision, f.err
+}
+
+func (f *fakeAuthorizer) Generation() int64 {
+	return f.decision.Generation
+}
+
+const (
+	v1Request = `{
+  "apiVersion": "authorization.k8s.io/v1",
+  "kind": "SubjectAccessReview",
+  "spec": {
+    "resourceAttributes": {"namespace": "demo", "verb": "get", "version": "v1", "resource": "secrets", "name": "tls"},
+    "user": "system:serviceaccount:demo:demo-controller",
+    "groups": ["system:serviceaccounts", "system:authenticated"]
+  }
+}`
+	v1beta1Request = `{
+  "apiVersion": "authorization.k8s.io/v1beta1",
+  "kind": "SubjectAccessReview",
+  "spec": {
+    "resourceAttributes": {"namespace": "demo", "verb": "get", "version": "v1", "resource": "secrets", "name": "tls"},
+    "user": "system:serviceaccount:demo:demo-controller",
+    "group": ["system:serviceaccounts", "system:authenticated"]
+  }
+}`
+	nonResourceRequest = `{
+  "apiVersion": "authorization.k8s.io/v1",
+  "kind": "SubjectAccessReview",
+  "spec": {"nonResourceAttributes": {"path": "/healthz", "verb": "get"}, "user": "alice"}
+}`
+)
+
+func TestAuthzHandler(t *testing.T) {
+	wantSpec := authorizationv1.SubjectAccessReviewSpec{
+		ResourceAttributes: &authorizationv1.ResourceAttributes{Namespace: "demo", Verb: "get", Version: "v1", Resource: "secrets", Name: "tls"},
+		User:               "system:serviceaccount:demo:demo-controller",
+		Groups:             []string{"system:serviceaccounts", "system:authenticated"},
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        io.Reader
+		opts        AuthzOptions
+		authorizer  *fakeAuthorizer
+		wantCode    int
+		// wantStatus is only checked for 200 responses.
+		wantStatus     authorizationv1.SubjectAccessReviewStatus
+		wantReviews    []authorizationv1.SubjectAccessReviewSpec
+		wantGeneration string
+		// wantAPIVersion defaults to authorization.k8s.io/v1.
+		wantAPIVersion string
+	}{
+		{
+			name:     "GET",
+			method:   http.MethodGet,
+			wantCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:        "unsupported Content-Type",
+			contentType: "application/yaml",
+			body:        strings.NewReader(v1Request),
+			wantCode:    http.StatusUnsupportedMediaType,
+		},
+		{
+			name:     "body too large",
+			body:     strings.NewReader(v1Request),
+			opts:     AuthzOptions{MaxRequestBytes: 16},
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:     "read error",
+			body:     iotest.ErrReader(errors.New("connection reset")),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid JSON",
+			body:     strings.NewReader("{"),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "missing TypeMeta",
+			body:     strings.NewReader(`{"spec": {"user": "alice"}}`),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "unsupported apiVersion",
+			body:     strings.NewReader(strings.Replace(v1Request, "authorization.k8s.io/v1", "authorization.k8s.io/v2", 1)),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:       "non-resource request",
+			body:       strings.NewReader(nonResourceRequest),
+			wantCode:   http.StatusOK,
+			wantStatus: authorizationv1.SubjectAccessReviewStatus{Reason: "No opinion on non-resource requests"},
+		},
+		{
+			name:       "unprotected resource",
+			body:       strings.NewReader(v1Request),
+			opts:       AuthzOptions{ProtectedResources: []string{"configmaps"}},
+			wantCode:   http.StatusOK,
+			wantStatus: authorizationv1.SubjectAccessReviewStatus{Reason: "No opinion on unprotected resources"},
+		},
+		{
+			name:           "allowed",
+			contentType:    "application/json; charset=utf-8",
+			body:           strings.NewReader(v1Request),
+			authorizer:     &fakeAuthorizer{decision: store.Decision{Allowed: true, Generation: 3}},
+			wantCode:       http.StatusOK,
+			wantStatus:     authorizationv1.SubjectAccessReviewStatus{Allowed: true},
+			wantReviews:    []authorizationv1.SubjectAccessReviewSpec{wantSpec},
+			wantGeneration: "3",
+		},
+		{
+			name:       "not allowed",
+			body:       strings.NewReader(v1Request),
+			authorizer: &fakeAuthorizer{decision: store.Decision{Generation: 3}},
+			wantCode:   http.StatusOK,
+			wantStatus: authorizationv1.SubjectAccessReviewStatus{
+				Reason: `Referential authorizer did not allow Subject "system:serviceaccount:demo:demo-controller" to get /secrets/demo/tls `,
+			},
+			wantReviews:    []authorizationv1.SubjectAccessReviewSpec{wantSpec},
+			wantGeneration: "3",
+		},
+		{
+			name:           "generation in reason",
+			body:           strings.NewReader(v1Request),
+			opts:           AuthzOptions{GenerationInReason: true},
+			authorizer:     &fakeAuthorizer{decision: store.Decision{Allowed: true, Generation: 3}},
+			wantCode:       http.StatusOK,
+			wantStatus:     authorizationv1.SubjectAccessReviewStatus{Allowed: true, Reason: "(graph generation 3)"},
+			wantReviews:    []authorizationv1.SubjectAccessReviewSpec{wantSpec},
+			wantGeneration: "3",
+		},
+		{
+			name:           "evaluation error",
+			body:           strings.NewReader(v1Request),
+			authorizer:     &fakeAuthorizer{err: errors.New("graph unavailable")},
+			wantCode:       http.StatusOK,
+			wantStatus:     authorizationv1.SubjectAccessReviewStatus{EvaluationError: "graph unavailable"},
+			wantReviews:    []authorizationv1.SubjectAccessReviewSpec{wantSpec},
+			wantGeneration: "0",
+		},
+		{
+			name:           "v1beta1 request",
+			body:           strings.NewReader(v1beta1Request),
+			wantAPIVersion: "authorization.k8s.io/v1beta1",
+			authorizer:     &fakeAuthorizer{decision: store.Decision{Allowed: true, Generation: 3}},
+			wantCode:       http.StatusOK,
+			wantStatus:     authorizationv1.SubjectAccessReviewStatus{Allowed: true},
+			wantReviews:    []authorizationv1.SubjectAccessReviewSpec{wantSpec},
+			wantGeneration: "3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authorizer := tt.authorizer
+			if authorizer == nil {
+				authorizer = &fakeAuthorizer{}
+			}
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, "/authorize", tt.body)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			AuthzHandler(authorizer, tt.opts).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("unexpected status code %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			reviews := []authorizationv1.SubjectAccessReviewSpec{}
+			for _, sar := range authorizer.reviews {
+				reviews = append(reviews, sar.Spec)
+			}
+			if diff := cmp.Diff(tt.wantReviews, reviews, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("reviews mismatch (-want +got):\n%s", diff)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			if got := rec.Header().Get(GenerationHeader); got != tt.wantGeneration {
+				t.Errorf("%s header = %q, want %q", GenerationHeader, got, tt.wantGeneration)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			resp := authorizationv1.SubjectAccessReview{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			want := authorizationv1.SubjectAccessReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "authorization.k8s.io/v1", Kind: "SubjectAccessReview"},
+				Status:   tt.wantStatus,
+			}
+			if tt.wantAPIVersion != "" {
+				want.APIVersion = tt.wantAPIVersion
+			}
+			if diff := cmp.Diff(want, resp); diff != "" {
+				t.Errorf("response mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGraphHandler(t *testing.T) {
+	alice := v1a1.Subject{Kind: "User", Name: "alice"}
+	bob := v1a1.Subject{Kind: "User", Name: "bob"}
+	gatewaySecretsKey := "gateway.networking.k8s.io/gateways;/secrets;tls-serving"
+	podSecretsKey := "/pods;/secrets;env"
+
+	s := store.NewAuthStore()
+	s.ReplaceGraphKey(gatewaySecretsKey, []store.Edge{
+		{Target: types.NamespacedName{Namespace: "demo", Name: "tls"}, Subjects: []v1a1.Subject{alice}},
+		{Target: types.NamespacedName{Namespace: "shared", Name: "tls"}, Subjects: []v1a1.Subject{alice, bob}},
+	}, store.KeyProvenance{})
+	s.ReplaceGraphKey(podSecretsKey, []store.Edge{
+		{Target: types.NamespacedName{Namespace: "demo", Name: "tls"}, Subjects: []v1a1.Subject{bob}},
+	}, store.KeyProvenance{})
+
+	tests := []struct {
+		name       string
+		query      string
+		wantFilter store.GraphFilter
+	}{
+		{name: "no query"},
+		{name: "user", query: "user=alice", wantFilter: store.GraphFilter{Subject: &alice}},
+		{name: "key", query: "key=%2Fpods%3B%2Fsecrets%3Benv", wantFilter: store.GraphFilter{Key: podSecretsKey}},
+		{name: "namespace", query: "namespace=shared", wantFilter: store.GraphFilter{Namespace: "shared"}},
+		{
+			name:       "all",
+			query:      "user=bob&key=gateway.networking.k8s.io%2Fgateways%3B%2Fsecrets%3Btls-serving&namespace=demo",
+			wantFilter: store.GraphFilter{Subject: &bob, Key: gatewaySecretsKey, Namespace: "demo"},
+		},
+		{name: "empty user", query: "user=", wantFilter: store.GraphFilter{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			GraphHandler(s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/graph?"+tt.query, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			got := store.GraphView{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if diff := cmp.Diff(s.View(tt.wantFilter), got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("response mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
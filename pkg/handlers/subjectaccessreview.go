@@ -0,0 +1,148 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handlers
+
+import (
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	authorizationv1beta1 "k8s.io/api/authorization/v1beta1"
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+)
+
+// The wire versions of SubjectAccessReviews that kube-apiservers can be
+// configured to send, see subjectAccessReviewVersion of the webhook.
+var (
+	reviewScheme = runtime.NewScheme()
+	reviewCodecs = serializer.NewCodecFactory(reviewScheme)
+)
+
+func init() {
+	utilruntime.Must(authorizationv1.AddToScheme(reviewScheme))
+	utilruntime.Must(authorizationv1beta1.AddToScheme(reviewScheme))
+	utilruntime.Must(reviewScheme.AddConversionFunc((*authorizationv1beta1.SubjectAccessReview)(nil), (*authorizationv1.SubjectAccessReview)(nil),
+		func(a, b interface{}, _ conversion.Scope) error {
+			*b.(*authorizationv1.SubjectAccessReview) = subjectAccessReviewFromV1beta1(*a.(*authorizationv1beta1.SubjectAccessReview))
+			return nil
+		}))
+	utilruntime.Must(reviewScheme.AddConversionFunc((*authorizationv1.SubjectAccessReview)(nil), (*authorizationv1beta1.SubjectAccessReview)(nil),
+		func(a, b interface{}, _ conversion.Scope) error {
+			*b.(*authorizationv1beta1.SubjectAccessReview) = subjectAccessReviewToV1beta1(*a.(*authorizationv1.SubjectAccessReview))
+			return nil
+		}))
+}
+
+// decodeSubjectAccessReview decodes a SubjectAccessReview of any wire version
+// into v1, and returns the version it was sent in.
+func decodeSubjectAccessReview(content []byte) (authorizationv1.SubjectAccessReview, schema.GroupVersion, error) {
+	// Without a target, requests must be self-describing.
+	obj, gvk, err := reviewCodecs.UniversalDeserializer().Decode(content, nil, nil)
+	if err != nil {
+		return authorizationv1.SubjectAccessReview{}, schema.GroupVersion{}, err
+	}
+	sar := authorizationv1.SubjectAccessReview{}
+	if err := reviewScheme.Convert(obj, &sar, nil); err != nil {
+		return authorizationv1.SubjectAccessReview{}, schema.GroupVersion{}, fmt.Errorf("expected a SubjectAccessReview: %w", err)
+	}
+	return sar, gvk.GroupVersion(), nil
+}
+
+// encodeSubjectAccessReview encodes a v1 SubjectAccessReview in version gv.
+func encodeSubjectAccessReview(sar *authorizationv1.SubjectAccessReview, gv schema.GroupVersion) ([]byte, error) {
+	return runtime.Encode(reviewCodecs.LegacyCodec(gv), sar)
+}
+
+// subjectAccessReviewFromV1beta1 converts a v1beta1 SubjectAccessReview to v1.
+// The versions only differ by the JSON name of the groups of the subject.
+func subjectAccessReviewFromV1beta1(in authorizationv1beta1.SubjectAccessReview) authorizationv1.SubjectAccessReview {
+	out := authorizationv1.SubjectAccessReview{
+		ObjectMeta: in.ObjectMeta,
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   in.Spec.User,
+			Groups: in.Spec.Groups,
+			UID:    in.Spec.UID,
+		},
+		Status: authorizationv1.SubjectAccessReviewStatus(in.Status),
+	}
+	if attrs := in.Spec.ResourceAttributes; attrs != nil {
+		out.Spec.ResourceAttributes = &authorizationv1.ResourceAttributes{
+			Namespace:   attrs.Namespace,
+			Verb:        attrs.Verb,
+			Group:       attrs.Group,
+			Version:     attrs.Version,
+			Resource:    attrs.Resource,
+			Subresource: attrs.Subresource,
+			Name:        attrs.Name,
+			// v1beta1 shares the selector types of v1.
+			FieldSelector: attrs.FieldSelector,
+			LabelSelector: attrs.LabelSelector,
+		}
+	}
+	if attrs := in.Spec.NonResourceAttributes; attrs != nil {
+		nonResourceAttributes := authorizationv1.NonResourceAttributes(*attrs)
+		out.Spec.NonResourceAttributes = &nonResourceAttributes
+	}
+	if in.Spec.Extra != nil {
+		out.Spec.Extra = make(map[string]authorizationv1.ExtraValue, len(in.Spec.Extra))
+		for k, v := range in.Spec.Extra {
+			out.Spec.Extra[k] = authorizationv1.ExtraValue(v)
+		}
+	}
+	return out
+}
+
+// subjectAccessReviewToV1beta1 converts a v1 SubjectAccessReview to v1beta1.
+func subjectAccessReviewToV1beta1(in authorizationv1.SubjectAccessReview) authorizationv1beta1.SubjectAccessReview {
+	out := authorizationv1beta1.SubjectAccessReview{
+		ObjectMeta: in.ObjectMeta,
+		Spec: authorizationv1beta1.SubjectAccessReviewSpec{
+			User:   in.Spec.User,
+			Groups: in.Spec.Groups,
+			UID:    in.Spec.UID,
+		},
+		Status: authorizationv1beta1.SubjectAccessReviewStatus(in.Status),
+	}
+	if attrs := in.Spec.ResourceAttributes; attrs != nil {
+		out.Spec.ResourceAttributes = &authorizationv1beta1.ResourceAttributes{
+			Namespace:   attrs.Namespace,
+			Verb:        attrs.Verb,
+			Group:       attrs.Group,
+			Version:     attrs.Version,
+			Resource:    attrs.Resource,
+			Subresource: attrs.Subresource,
+			Name:        attrs.Name,
+			// v1beta1 shares the selector types of v1.
+			FieldSelector: attrs.FieldSelector,
+			LabelSelector: attrs.LabelSelector,
+		}
+	}
+	if attrs := in.Spec.NonResourceAttributes; attrs != nil {
+		nonResourceAttributes := authorizationv1beta1.NonResourceAttributes(*attrs)
+		out.Spec.NonResourceAttributes = &nonResourceAttributes
+	}
+	if in.Spec.Extra != nil {
+		out.Spec.Extra = make(map[string]authorizationv1beta1.ExtraValue, len(in.Spec.Extra))
+		for k, v := range in.Spec.Extra {
+			out.Spec.Extra[k] = authorizationv1beta1.ExtraValue(v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics defines the Prometheus metrics of the authorizer and the
+// controller. They are registered with the controller-runtime registry, so
+// they are served together with the manager's own metrics.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+const namespace = "referencegrant"
+
+// Values of the decision label.
+const (
+	DecisionAllowed   = "allowed"
+	DecisionDenied    = "denied"
+	DecisionNoOpinion = "no_opinion"
+)
+
+// Values of the result label of cache lookups.
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+var (
+	// Decisions counts authorization decisions by decision, the group and
+	// resource of the target, and the purpose access was granted for.
+	Decisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "authorizer",
+		Name:      "decisions_total",
+		Help:      "Number of authorization decisions by decision, target group and resource, and purpose.",
+	}, []string{"decision", "group", "resource", "purpose"})
+
+	// CheckAuthzDuration observes how long the graph lookup of a
+	// SubjectAccessReview takes.
+	CheckAuthzDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "authorizer",
+		Name:      "check_duration_seconds",
+		Help:      "Latency of looking up a SubjectAccessReview in the reference graph.",
+		Buckets:   prometheus.ExponentialBuckets(0.00001, 4, 10),
+	})
+
+	// DecisionCacheLookups counts lookups of the decision cache by result.
+	DecisionCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "authorizer",
+		Name:      "decision_cache_lookups_total",
+		Help:      "Number of lookups of the authorization decision cache by result.",
+	}, []string{"result"})
+
+	// GraphKeys is the number of from-to-for keys in the graph.
+	GraphKeys = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "graph",
+		Name:      "keys",
+		Help:      "Number of from-to-for keys in the reference graph.",
+	})
+
+	// GraphEdges is the number of subject to target edges in the graph,
+	// counted once per from-to-for key.
+	GraphEdges = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "graph",
+		Name:      "edges",
+		Help:      "Number of subject to target edges in the reference graph.",
+	})
+
+	// GraphSubjects is the number of subjects granted access to any target.
+	GraphSubjects = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "graph",
+		Name:      "subjects",
+		Help:      "Number of subjects in the reference graph.",
+	})
+
+	// ReconcileDuration observes how long recomputing a from-to-for key takes.
+	ReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "reconcile",
+		Name:      "duration_seconds",
+		Help:      "Duration of recomputing the reference graph for a from-to-for key.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"key"})
+
+	// ReconcileErrors counts failures to recompute a from-to-for key.
+	ReconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "reconcile",
+		Name:      "errors_total",
+		Help:      "Number of failures to recompute the reference graph for a from-to-for key.",
+	}, []string{"key"})
+
+	// FromObjectsScanned is the number of From objects whose references were
+	// followed by the last reconciliation of a from-to-for key.
+	FromObjectsScanned = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "reconcile",
+		Name:      "from_objects_scanned",
+		Help:      "Number of From objects scanned by the last reconciliation of a from-to-for key.",
+	}, []string{"key"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		Decisions,
+		CheckAuthzDuration,
+		DecisionCacheLookups,
+		GraphKeys,
+		GraphEdges,
+		GraphSubjects,
+		ReconcileDuration,
+		ReconcileErrors,
+		FromObjectsScanned,
+	)
+}
+
+// ObserveGraph sets the graph gauges to the size of the graph in s.
+func ObserveGraph(s *store.AuthStore) {
+	keys, edges, subjects := s.Stats()
+	GraphKeys.Set(float64(keys))
+	GraphEdges.Set(float64(edges))
+	GraphSubjects.Set(float64(subjects))
+}
+
+// Handler serves the metrics of the controller-runtime registry, which
+// includes the metrics above as well as the manager's own.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(ctrlmetrics.Registry, promhttp.HandlerOpts{})
+}
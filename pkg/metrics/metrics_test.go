@@ -0,0 +1,80 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+func TestRegistered(t *testing.T) {
+	collectors := map[string]prometheus.Collector{
+		"Decisions":            Decisions,
+		"CheckAuthzDuration":   CheckAuthzDuration,
+		"DecisionCacheLookups": DecisionCacheLookups,
+		"GraphKeys":            GraphKeys,
+		"GraphEdges":           GraphEdges,
+		"GraphSubjects":        GraphSubjects,
+		"ReconcileDuration":    ReconcileDuration,
+		"ReconcileErrors":      ReconcileErrors,
+		"FromObjectsScanned":   FromObjectsScanned,
+	}
+	for name, collector := range collectors {
+		err := ctrlmetrics.Registry.Register(collector)
+		if !errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+			t.Errorf("%s is not registered with the controller-runtime registry: %v", name, err)
+		}
+		if problems, err := testutil.CollectAndLint(collector); err != nil || len(problems) > 0 {
+			t.Errorf("%s fails linting: %v %v", name, problems, err)
+		}
+	}
+}
+
+func TestObserveGraph(t *testing.T) {
+	alice := v1a1.Subject{Kind: "User", Name: "alice"}
+	bob := v1a1.Subject{Kind: "User", Name: "bob"}
+	s := store.NewAuthStore()
+	s.ReplaceGraphKey("gateway.networking.k8s.io/gateways;/secrets;tls-serving", []store.Edge{
+		{Target: types.NamespacedName{Namespace: "demo", Name: "tls"}, Subjects: []v1a1.Subject{alice, bob}},
+		{Target: types.NamespacedName{Namespace: "demo", Name: "other"}, Subjects: []v1a1.Subject{alice}},
+	}, store.KeyProvenance{})
+	s.ReplaceGraphKey("/pods;/secrets;env", []store.Edge{
+		{Target: types.NamespacedName{Namespace: "demo", Name: "tls"}, Subjects: []v1a1.Subject{bob}},
+	}, store.KeyProvenance{})
+
+	ObserveGraph(s)
+
+	for name, want := range map[string]struct {
+		gauge prometheus.Gauge
+		value float64
+	}{
+		"keys":     {GraphKeys, 2},
+		"edges":    {GraphEdges, 4},
+		"subjects": {GraphSubjects, 2},
+	} {
+		if got := testutil.ToFloat64(want.gauge); got != want.value {
+			t.Errorf("graph %s = %v, want %v", name, got, want.value)
+		}
+	}
+}
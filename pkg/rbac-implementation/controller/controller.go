@@ -14,463 +14,508 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-package main
+package controller
 
 import (
 	"context"
-	"os"
-
-	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
 
 	"github.com/go-logr/logr"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/klog/v2/klogr"
-	"k8s.io/klog/v2/textlogger"
-	ctrl "sigs.k8s.io/controller-runtime"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
 )
 
 const (
-	labelKeyPatternName = "reference.authorization.k8s.io/pattern-name"
+	// LabelKeyPatternName is set on every generated Role and RoleBinding. Its
+	// value is derived from the from-to-for key the object was generated for.
+	LabelKeyPatternName = "reference.authorization.k8s.io/pattern-name"
+
+	// AnnotationKeyPattern holds the from-to-for key a generated Role or
+	// RoleBinding was generated for, as label values can't hold it.
+	AnnotationKeyPattern = "reference.authorization.k8s.io/pattern"
+
+	// namePrefix prefixes the names of generated Roles and RoleBindings.
+	namePrefix = "reference.authorization.k8s.io:"
+
+	// maxNameLength is the maximum length of a Role or RoleBinding name.
+	maxNameLength = 253
 )
 
-type Controller struct {
-	dClient  *dynamic.DynamicClient
-	crClient client.Client
-	log      logr.Logger
+// baseVerbs are granted on every referenced resource name. list and watch are
+// only usable with a metadata.name field selector, as RBAC limits them to the
+// ResourceNames of the rule.
+var baseVerbs = []string{"get", "list", "watch"}
+
+// Reconciler materializes the reference graph computed for a from-to-for key
+// as Roles and RoleBindings with ResourceNames. It is an alternate
+// enforcement backend for clusters that can't configure an authorization
+// webhook.
+//
+// Generated objects have deterministic names per from-to-for key, namespace
+// and set of subjects, and are owned by every ClusterReferenceGrant that defines a
+// reference path for their key, so they are garbage collected once the last
+// of those is deleted.
+type Reconciler struct {
+	client client.Client
+	log    logr.Logger
+}
+
+func NewReconciler(c client.Client, log logr.Logger) *Reconciler {
+	return &Reconciler{
+		client: c,
+		log:    log.WithName("rbac"),
+	}
+}
+
+// PatternName returns the value of the LabelKeyPatternName label for the
+// from-to-for key. Keys contain characters that are not valid in label values,
+// so they are hashed.
+func PatternName(key string) string {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// ObjectName returns the name of the Role and RoleBinding generated for the
+// from-to-for key in a namespace, for the targets granted to subjects. For
+// example, the key "gateway.networking.k8s.io/gateways;/secrets;tls-serving"
+// is named
+// "reference.authorization.k8s.io:gateways.gateway.networking.k8s.io:secrets:tls-serving:0123abcd",
+// where the suffix is derived from the subjects. Keys that would exceed the
+// maximum name length fall back to their PatternName.
+func ObjectName(key string, subjects []rbacv1.Subject) string {
+	return keyName(key) + ":" + subjectsName(subjects)
 }
 
-func NewController() *Controller {
-	lConfig := textlogger.NewConfig()
+// keyName returns the part of the names of the Roles and RoleBindings
+// generated for the from-to-for key that doesn't depend on their subjects.
+func keyName(key string) string {
+	splitKey := strings.Split(key, ";")
+	if len(splitKey) != 3 {
+		return namePrefix + PatternName(key)
+	}
+	name := fmt.Sprintf("%s%s:%s:%s", namePrefix, resourceDotGroup(splitKey[0]), resourceDotGroup(splitKey[1]), splitKey[2])
+	if len(name) > maxNameLength-len(":00000000") {
+		return namePrefix + PatternName(key)
+	}
+	return name
+}
+
+// subjectsName hashes sorted subjects into a name suffix.
+func subjectsName(subjects []rbacv1.Subject) string {
+	h := fnv.New32a()
+	for _, subject := range subjects {
+		fmt.Fprintf(h, "%s/%s/%s;", subject.Kind, subject.Namespace, subject.Name)
+	}
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// isObjectName reports whether name is the name of a Role or RoleBinding
+// generated for the from-to-for key.
+func isObjectName(key, name string) bool {
+	suffix, ok := strings.CutPrefix(name, keyName(key)+":")
+	return ok && len(suffix) == len("00000000") && !strings.Contains(suffix, ":")
+}
+
+// resourceDotGroup formats "group/resource" as "resource.group", or just
+// "resource" for the core group.
+func resourceDotGroup(groupResource string) string {
+	gr := strings.SplitN(groupResource, "/", 2)
+	if len(gr) != 2 {
+		return groupResource
+	}
+	if gr[0] == "" {
+		return gr[1]
+	}
+	return gr[1] + "." + gr[0]
+}
+
+type reconciliationResults struct {
+	rolesCreated        int32
+	rolesUpdated        int32
+	rolesDeleted        int32
+	roleBindingsCreated int32
+	roleBindingsUpdated int32
+	roleBindingsDeleted int32
+}
+
+func (rr reconciliationResults) changed() bool {
+	return rr != reconciliationResults{}
+}
+
+// ReconcileKey makes the Roles and RoleBindings generated for the from-to-for
+// key match the given grants. It generates one Role and RoleBinding per
+// namespace and set of subjects, granting them only the targets all of those
+// subjects are granted, owned by the given ClusterReferenceGrants. Only
+// objects that differ from the desired state are written. The results are
+// reported in the status of the owners.
+func (r *Reconciler) ReconcileKey(ctx context.Context, key string, grants map[types.NamespacedName]sets.Set[v1a1.Subject], owners []v1a1.ClusterReferenceGrant) error {
+	var err error
+	rr := reconciliationResults{}
+	listOption := client.MatchingLabels{
+		LabelKeyPatternName: PatternName(key),
+	}
 
-	c := &Controller{
-		log: textlogger.NewLogger(lConfig),
+	splitKey := strings.Split(key, ";")
+	if len(splitKey) != 3 {
+		return fmt.Errorf("invalid from-to-for key %q", key)
+	}
+	to := strings.SplitN(splitKey[1], "/", 2)
+	if len(to) != 2 {
+		return fmt.Errorf("invalid target group resource in key %q", key)
+	}
+	group, resource := to[0], to[1]
+
+	// Targets of a namespace are granted together to the subjects they share,
+	// so that no subject is granted a target it can't access in the graph.
+	desired := map[types.NamespacedName]*roleSet{}
+	for nn, subjects := range grants {
+		// Cluster-scoped targets would need ClusterRoles, which are not
+		// generated. Nothing is generated without owners either, as there
+		// would be nothing to garbage collect the objects.
+		if nn.Namespace == "" || subjects.Len() == 0 || len(owners) == 0 {
+			continue
+		}
+		rbacSubjs := rbacSubjects(subjects)
+		objectName := types.NamespacedName{Namespace: nn.Namespace, Name: ObjectName(key, rbacSubjs)}
+		if _, ok := desired[objectName]; !ok {
+			desired[objectName] = &roleSet{subjects: rbacSubjs, names: sets.New[string]()}
+		}
+		desired[objectName].names.Insert(nn.Name)
 	}
-	ctrl.SetLogger(klogr.New())
 
-	c.log.Info("Initializing Controller")
+	ownerReferences := ownerReferencesFor(owners)
+	objectMeta := func(nn types.NamespacedName) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:            nn.Name,
+			Namespace:       nn.Namespace,
+			Labels:          map[string]string{LabelKeyPatternName: PatternName(key)},
+			Annotations:     map[string]string{AnnotationKeyPattern: key},
+			OwnerReferences: ownerReferences,
+		}
+	}
 
-	kConfig := ctrl.GetConfigOrDie()
-	scheme := scheme.Scheme
-	v1a1.AddToScheme(scheme)
+	desiredRoles := map[types.NamespacedName]*rbacv1.Role{}
+	desiredRoleBindings := map[types.NamespacedName]*rbacv1.RoleBinding{}
+	for nn, rs := range desired {
+		desiredRoles[nn] = &rbacv1.Role{
+			ObjectMeta: objectMeta(nn),
+			Rules: []rbacv1.PolicyRule{{
+				APIGroups:     []string{group},
+				Resources:     []string{resource},
+				Verbs:         baseVerbs,
+				ResourceNames: sets.List(rs.names),
+			}},
+		}
+		desiredRoleBindings[nn] = &rbacv1.RoleBinding{
+			ObjectMeta: objectMeta(nn),
+			Subjects:   rs.subjects,
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.SchemeGroupVersion.Group,
+				Kind:     "Role",
+				Name:     nn.Name,
+			},
+		}
+	}
 
-	dClient, err := dynamic.NewForConfig(kConfig)
+	roleList := rbacv1.RoleList{}
+	err = r.client.List(ctx, &roleList, listOption)
 	if err != nil {
-		c.log.Error(err, "could not create Dynamic client")
-		os.Exit(1)
+		r.log.Error(err, "error listing Roles")
+		return err
+	}
+	for i := range roleList.Items {
+		er := &roleList.Items[i]
+		nn := types.NamespacedName{Namespace: er.Namespace, Name: er.Name}
+		dr, isDesired := desiredRoles[nn]
+
+		// Anything that doesn't have the deterministic name of a desired
+		// set of targets is left over and gets deleted.
+		if !isDesired {
+			r.log.Info("Deleting Role", "namespace", er.Namespace, "name", er.Name)
+			err := r.client.Delete(ctx, er)
+			if client.IgnoreNotFound(err) != nil {
+				r.log.Error(err, "error deleting Role")
+				return err
+			}
+			rr.rolesDeleted++
+			continue
+		}
+
+		delete(desiredRoles, nn)
+		if equality.Semantic.DeepEqual(er.Rules, dr.Rules) &&
+			equality.Semantic.DeepEqual(er.OwnerReferences, dr.OwnerReferences) {
+			continue
+		}
+		er.Rules = dr.Rules
+		er.OwnerReferences = dr.OwnerReferences
+		r.log.Info("Updating Role", "namespace", er.Namespace, "name", er.Name)
+		err := r.client.Update(ctx, er)
+		if err != nil {
+			r.log.Error(err, "error updating Role")
+			return err
+		}
+		rr.rolesUpdated++
 	}
 
-	c.dClient = dClient
+	for _, nn := range sortedKeys(desiredRoles) {
+		dr := desiredRoles[nn]
+		r.log.Info("Creating Role", "namespace", dr.Namespace, "name", dr.Name)
+		err := r.client.Create(ctx, dr)
+		if err != nil {
+			r.log.Error(err, "error creating Role")
+			return err
+		}
+		rr.rolesCreated++
+	}
 
-	manager, err := ctrl.NewManager(kConfig, ctrl.Options{Scheme: scheme})
+	roleBindingList := rbacv1.RoleBindingList{}
+	err = r.client.List(ctx, &roleBindingList, listOption)
 	if err != nil {
-		c.log.Error(err, "could not create manager")
-		os.Exit(1)
+		r.log.Error(err, "error listing RoleBindings")
+		return err
+	}
+	for i := range roleBindingList.Items {
+		erb := &roleBindingList.Items[i]
+		nn := types.NamespacedName{Namespace: erb.Namespace, Name: erb.Name}
+		drb, isDesired := desiredRoleBindings[nn]
+
+		// The RoleRef of a RoleBinding can't be changed, so RoleBindings
+		// referring to another Role are deleted and recreated.
+		if !isDesired || erb.RoleRef != drb.RoleRef {
+			r.log.Info("Deleting RoleBinding", "namespace", erb.Namespace, "name", erb.Name)
+			err := r.client.Delete(ctx, erb)
+			if client.IgnoreNotFound(err) != nil {
+				r.log.Error(err, "error deleting RoleBinding")
+				return err
+			}
+			rr.roleBindingsDeleted++
+			continue
+		}
+
+		delete(desiredRoleBindings, nn)
+		if equality.Semantic.DeepEqual(erb.Subjects, drb.Subjects) &&
+			equality.Semantic.DeepEqual(erb.OwnerReferences, drb.OwnerReferences) {
+			continue
+		}
+		erb.Subjects = drb.Subjects
+		erb.OwnerReferences = drb.OwnerReferences
+		r.log.Info("Updating RoleBinding", "namespace", erb.Namespace, "name", erb.Name)
+		err := r.client.Update(ctx, erb)
+		if err != nil {
+			r.log.Error(err, "error updating RoleBinding")
+			return err
+		}
+		rr.roleBindingsUpdated++
+	}
+
+	for _, nn := range sortedKeys(desiredRoleBindings) {
+		drb := desiredRoleBindings[nn]
+		r.log.Info("Creating RoleBinding", "namespace", drb.Namespace, "name", drb.Name)
+		err := r.client.Create(ctx, drb)
+		if err != nil {
+			r.log.Error(err, "error creating RoleBinding")
+			return err
+		}
+		rr.roleBindingsCreated++
 	}
 
-	c.crClient = manager.GetClient()
+	r.log.Info("Completed RBAC Reconciliation", "key", key, "Results", fmt.Sprintf("%+v", rr))
+
+	return r.updateStatus(ctx, owners, group, resource, splitKey[2], int32(len(desired)), rr)
+}
+
+// roleSet is the names of the targets of a key in a namespace that are
+// granted to the same subjects.
+type roleSet struct {
+	subjects []rbacv1.Subject
+	names    sets.Set[string]
+}
 
-	// TODO: Add selective ClusterRole and RoleBinding watchers here
-	err = ctrl.NewControllerManagedBy(manager).
-		Named("referencegrant-poc").
-		Watches(&v1a1.ClusterReferenceConsumer{}, NewClusterReferenceConsumerHandler(c)).
-		Watches(&v1a1.ClusterReferenceGrant{}, NewClusterReferenceGrantHandler(c)).
-		Watches(&v1a1.ReferenceGrant{}, NewReferenceGrantHandler(c)).
-		Complete(c)
+func sortedKeys[V any](m map[types.NamespacedName]V) []types.NamespacedName {
+	keys := make([]types.NamespacedName, 0, len(m))
+	for nn := range m {
+		keys = append(keys, nn)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Namespace != keys[j].Namespace {
+			return keys[i].Namespace < keys[j].Namespace
+		}
+		return keys[i].Name < keys[j].Name
+	})
+	return keys
+}
 
+// updateStatus records the results for the reference in the status of every
+// owner, and drops the results of references the owner no longer defines.
+// Owners are only written when their status changes.
+func (r *Reconciler) updateStatus(ctx context.Context, owners []v1a1.ClusterReferenceGrant, group, resource, forReason string, roles int32, rr reconciliationResults) error {
+	for i := range owners {
+		crg := owners[i].DeepCopy()
+		crg.Status.ReconciliationResults = definedResults(crg)
+		var current *v1a1.ReconciliationResults
+		for j := range crg.Status.ReconciliationResults {
+			res := &crg.Status.ReconciliationResults[j]
+			if res.Group == group && res.Resource == resource && res.For == forReason {
+				current = res
+				break
+			}
+		}
+		if current == nil {
+			crg.Status.ReconciliationResults = append(crg.Status.ReconciliationResults, v1a1.ReconciliationResults{
+				Group:    group,
+				Resource: resource,
+				For:      forReason,
+			})
+			current = &crg.Status.ReconciliationResults[len(crg.Status.ReconciliationResults)-1]
+		}
+
+		current.Roles = roles
+		current.RoleBindings = roles
+		if rr.changed() {
+			now := metav1.Now()
+			current.RolesCreated = rr.rolesCreated
+			current.RolesUpdated = rr.rolesUpdated
+			current.RolesDeleted = rr.rolesDeleted
+			current.RoleBindingsCreated = rr.roleBindingsCreated
+			current.RoleBindingsUpdated = rr.roleBindingsUpdated
+			current.RoleBindingsDeleted = rr.roleBindingsDeleted
+			current.LastChangeTime = &now
+		}
+		if equality.Semantic.DeepEqual(crg.Status, owners[i].Status) {
+			continue
+		}
+
+		r.log.Info("Updating ClusterReferenceGrant status", "name", crg.Name)
+		err := r.client.Status().Patch(ctx, crg, client.MergeFrom(&owners[i]))
+		if client.IgnoreNotFound(err) != nil {
+			r.log.Error(err, "error updating ClusterReferenceGrant status")
+			return err
+		}
+	}
+	return nil
+}
+
+// definedResults returns the reconciliation results in the status of crg for
+// the references it still defines.
+func definedResults(crg *v1a1.ClusterReferenceGrant) []v1a1.ReconciliationResults {
+	// We ignore multiple versions for now, as the graph does.
+	defined := sets.New[v1a1.ReferencePath]()
+	if len(crg.Versions) > 0 {
+		for _, ref := range crg.Versions[0].References {
+			defined.Insert(v1a1.ReferencePath{To: ref.To, For: ref.For})
+		}
+	}
+	results := []v1a1.ReconciliationResults{}
+	for _, res := range crg.Status.ReconciliationResults {
+		ref := v1a1.ReferencePath{To: v1a1.GroupResource{Group: res.Group, Resource: res.Resource}, For: res.For}
+		if defined.Has(ref) {
+			results = append(results, res)
+		}
+	}
+	return results
+}
+
+// CleanupOrphans deletes generated Roles and RoleBindings that don't belong to
+// any of the given from-to-for keys, or don't have the deterministic name for
+// their key. It is meant to be run at startup, to clean up after references
+// that were removed while the controller wasn't running.
+func (r *Reconciler) CleanupOrphans(ctx context.Context, keys sets.Set[string]) error {
+	generated, err := labels.NewRequirement(LabelKeyPatternName, selection.Exists, nil)
 	if err != nil {
-		c.log.Error(err, "could not setup controller")
-		os.Exit(1)
+		return err
 	}
+	listOption := client.MatchingLabelsSelector{Selector: labels.NewSelector().Add(*generated)}
 
-	if err := manager.Start(ctrl.SetupSignalHandler()); err != nil {
-		c.log.Error(err, "could not start manager")
-		os.Exit(1)
+	isOrphan := func(obj client.Object) bool {
+		key, ok := obj.GetAnnotations()[AnnotationKeyPattern]
+		return !ok || !keys.Has(key) || !isObjectName(key, obj.GetName())
 	}
 
-	return c
+	roleList := rbacv1.RoleList{}
+	if err := r.client.List(ctx, &roleList, listOption); err != nil {
+		r.log.Error(err, "error listing Roles")
+		return err
+	}
+	for i := range roleList.Items {
+		role := &roleList.Items[i]
+		if !isOrphan(role) {
+			continue
+		}
+		r.log.Info("Deleting orphaned Role", "namespace", role.Namespace, "name", role.Name)
+		if err := r.client.Delete(ctx, role); client.IgnoreNotFound(err) != nil {
+			r.log.Error(err, "error deleting Role")
+			return err
+		}
+	}
+
+	roleBindingList := rbacv1.RoleBindingList{}
+	if err := r.client.List(ctx, &roleBindingList, listOption); err != nil {
+		r.log.Error(err, "error listing RoleBindings")
+		return err
+	}
+	for i := range roleBindingList.Items {
+		rb := &roleBindingList.Items[i]
+		if !isOrphan(rb) {
+			continue
+		}
+		r.log.Info("Deleting orphaned RoleBinding", "namespace", rb.Namespace, "name", rb.Name)
+		if err := r.client.Delete(ctx, rb); client.IgnoreNotFound(err) != nil {
+			r.log.Error(err, "error deleting RoleBinding")
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (c *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	c.log.Info("Reconciling for", "name", req.NamespacedName.Name)
-	return ctrl.Result{}, nil
+// ownerReferencesFor returns sorted, non-controlling owner references to the
+// ClusterReferenceGrants. Cluster-scoped owners are valid for the namespaced
+// generated objects.
+func ownerReferencesFor(owners []v1a1.ClusterReferenceGrant) []metav1.OwnerReference {
+	refs := make([]metav1.OwnerReference, 0, len(owners))
+	for _, crg := range owners {
+		refs = append(refs, metav1.OwnerReference{
+			APIVersion: v1a1.GroupVersion.String(),
+			Kind:       "ClusterReferenceGrant",
+			Name:       crg.Name,
+			UID:        crg.UID,
+		})
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Name < refs[j].Name
+	})
+	return refs
 }
 
-// 	// fromGroup, fromResource, toGroup, toResource, forReason := parseQueueKey(req.NamespacedName)
-// 	referenceGrants, clusterReferenceGrants, clusterReferenceConsumers, err := c.getResourcesFor(ctx, req.NamespacedName.Name)
-
-// 	for _, crg := range clusterReferenceGrants {
-// 		// TODO: Have informers for each target resource of a ClusterReferenceGrant
-// 		targetGVR := schema.GroupVersionResource{Group: crg.From.Group, Version: crg.From.Version, Resource: crg.From.Version}
-// 		targetList, err := c.dClient.Resource(targetGVR).List(context.TODO(), metav1.ListOptions{})
-// 		if err != nil {
-// 			c.log.Error(err, "failed to list target for ClusterReferenceGrant", targetGVR)
-// 			return ctrl.Result{}, err
-// 		}
-// 		refs := c.getReferences(ctx, targetList, crc.Path)
-// 	}
-
-// 	subjects := c.getSubjects(ctx, crcList, crp.Name)
-
-// 	// TODO: Don't just blindly trust references, ensure ReferenceGrant allows them first
-// 	err = c.reconcileRBAC(ctx, crp, subjects, refs)
-// 	if err != nil {
-// 		c.log.Error(err, "error reconciling RBAC")
-// 		return ctrl.Result{}, err
-// 	}
-
-// 	return ctrl.Result{}, nil
-// }
-
-// func (c *Controller) getSubjects(ctx context.Context, list *v1a1.ClusterReferenceConsumerList, patternName string) []rbacv1.Subject {
-// 	subjects := []rbacv1.Subject{}
-
-// 	for _, crc := range list.Items {
-// 		match := false
-// 		for _, pn := range crc.PatternNames {
-// 			if pn == patternName {
-// 				match = true
-// 				break
-// 			}
-// 		}
-
-// 		if match {
-// 			// TODO: Dedupe
-// 			subjects = append(subjects, crc.Subject)
-// 		}
-// 	}
-
-// 	return subjects
-// }
-
-// type reference struct {
-// 	Group         string
-// 	Resource      string
-// 	FromNamespace string
-// 	ToNamespace   string
-// 	Name          string
-// }
-
-// func (c *Controller) getReferences(ctx context.Context, list *unstructured.UnstructuredList, path string) []reference {
-// 	refs := []reference{}
-// 	for _, item := range list.Items {
-// 		j := jsonpath.New("test")
-// 		err := j.Parse(fmt.Sprintf("{%s}", path))
-// 		if err != nil {
-// 			c.log.Error(err, "error parsing JSON Path")
-// 		}
-// 		results := new(bytes.Buffer)
-// 		err = j.Execute(results, item.UnstructuredContent())
-// 		if err != nil {
-// 			c.log.Error(err, "error finding results with JSON Path")
-// 		}
-
-// 		rawRefs := strings.Split(results.String(), " ")
-
-// 		for _, rr := range rawRefs {
-// 			jr := map[string]string{}
-// 			err = json.Unmarshal([]byte(rr), &jr)
-// 			group, hasGroup := jr["group"]
-// 			if !hasGroup {
-// 				c.log.Info("Missing group in reference", "ref", jr)
-// 				continue
-// 			}
-// 			resource, hasResource := jr["resource"]
-// 			if !hasResource {
-// 				kind, hasKind := jr["kind"]
-// 				if !hasKind {
-// 					c.log.Info("Missing kind or resource in reference", "ref", jr)
-// 					continue
-// 				}
-// 				gvr, _ := meta.UnsafeGuessKindToResource(schema.GroupVersionKind{Group: group, Version: "v1", Kind: kind})
-// 				resource = gvr.Resource
-// 			}
-
-// 			namespace, hasNamespace := jr["namespace"]
-// 			if !hasNamespace {
-// 				namespace = item.GetNamespace()
-// 			}
-
-// 			name, hasName := jr["name"]
-// 			if !hasName {
-// 				c.log.Info("Missing name in reference", "ref", jr)
-// 				continue
-// 			}
-
-// 			refs = append(refs, reference{
-// 				Group:         group,
-// 				Resource:      resource,
-// 				FromNamespace: item.GetNamespace(),
-// 				ToNamespace:   namespace,
-// 				Name:          name,
-// 			})
-// 		}
-// 	}
-
-// 	return refs
-// }
-
-// // Format: group/resource
-// type groupResource string
-// type resourceNamesByGroupAndResource map[groupResource]sets.Set[string]
-
-// func (r *reference) GroupResource() groupResource {
-// 	return groupResource(fmt.Sprintf("%s/%s", r.Group, r.Resource))
-// }
-
-// // TODO: This is awful, find a better approach
-// func splitGroupResource(gr groupResource) (string, string) {
-// 	s := strings.Split(string(gr), "/")
-// 	return s[0], s[1]
-// }
-
-// type reconciliationResults struct {
-// 	rolesCreated        uint
-// 	rolesUpdated        uint
-// 	rolesDeleted        uint
-// 	roleBindingsCreated uint
-// 	roleBindingsUpdated uint
-// 	roleBindingsDeleted uint
-// }
-
-// func (c *Controller) reconcileRBAC(ctx context.Context, crp *v1a1.ClusterReferenceGrant, subjects []rbacv1.Subject, references []reference) error {
-// 	var err error
-// 	rr := reconciliationResults{}
-// 	listOption := client.MatchingLabels{
-// 		labelKeyPatternName: crp.Name,
-// 	}
-
-// 	// TODO: Clean this up + extract it out
-// 	// Namespace -> Group+Resource -> Resource Name
-// 	namespaceResourceNames := map[string]resourceNamesByGroupAndResource{}
-// 	for _, ref := range references {
-// 		r, hasNamespace := namespaceResourceNames[ref.ToNamespace]
-// 		if !hasNamespace {
-// 			r = resourceNamesByGroupAndResource{}
-// 			namespaceResourceNames[ref.ToNamespace] = r
-// 		}
-// 		gr := ref.GroupResource()
-// 		names, hasNames := r[gr]
-// 		if !hasNames {
-// 			names = sets.New[string]()
-// 			r[gr] = names
-// 		}
-// 		names.Insert(ref.Name)
-// 	}
-
-// 	baseVerbs := []string{"get", "watch", "list"}
-// 	namespaceRoleNames := map[string]string{}
-// 	desiredRoles := map[string]*rbacv1.Role{}
-
-// 	for ns, r := range namespaceResourceNames {
-// 		role := &rbacv1.Role{
-// 			ObjectMeta: metav1.ObjectMeta{
-// 				GenerateName: fmt.Sprintf("%s-", crp.Name),
-// 				Namespace:    ns,
-// 				Labels:       map[string]string{labelKeyPatternName: crp.Name},
-// 			},
-// 		}
-// 		for gr, nameSet := range r {
-// 			group, resource := splitGroupResource(gr)
-// 			names := nameSet.UnsortedList()
-// 			role.Rules = append(role.Rules, rbacv1.PolicyRule{
-// 				APIGroups:     []string{group},
-// 				Resources:     []string{resource},
-// 				Verbs:         baseVerbs,
-// 				ResourceNames: names,
-// 			})
-// 		}
-// 		desiredRoles[ns] = role
-// 	}
-
-// 	existingRoles := map[string]rbacv1.Role{}
-// 	roleList := rbacv1.RoleList{}
-// 	rolesToDelete := []rbacv1.Role{}
-// 	err = c.crClient.List(ctx, &roleList, listOption)
-// 	if err != nil {
-// 		c.log.Error(err, "error listing Roles")
-// 		return err
-// 	}
-// 	for _, role := range roleList.Items {
-// 		existingRole, isExisting := existingRoles[role.Namespace]
-// 		desiredRole, isDesired := desiredRoles[role.Namespace]
-
-// 		// We want at most one role per ClusterReferenceGrant and Namespace,
-// 		// anything beyond that should be deleted.
-// 		if !isExisting && isDesired {
-// 			existingRole = role
-// 			existingRoles[role.Namespace] = existingRole
-// 			desiredRole.Name = existingRole.Name
-// 			desiredRole.GenerateName = ""
-// 		} else {
-// 			rolesToDelete = append(rolesToDelete, role)
-// 		}
-// 	}
-
-// 	// TODO: Add proper reconciliation logic to compare desired and existing so
-// 	// we're not always updating resources even if they don't need to change.
-// 	for _, dr := range desiredRoles {
-// 		if dr.Name != "" {
-// 			c.log.Info("Updating role", "role", dr)
-// 			err := c.crClient.Update(ctx, dr)
-// 			if err != nil {
-// 				c.log.Error(err, "error updating Role")
-// 				return err
-// 			}
-// 			rr.rolesUpdated++
-// 		} else {
-// 			c.log.Info("Creating role", "role", dr)
-// 			err := c.crClient.Create(ctx, dr)
-// 			if err != nil {
-// 				c.log.Error(err, "error creating Role")
-// 				return err
-// 			}
-// 			rr.rolesCreated++
-// 		}
-// 		namespaceRoleNames[dr.Namespace] = dr.Name
-// 	}
-
-// 	for _, rtd := range rolesToDelete {
-// 		c.log.Info("Deleting role", "role", rtd)
-// 		err := c.crClient.Delete(ctx, &rtd)
-// 		if err != nil {
-// 			c.log.Error(err, "error deleting Role")
-// 			return err
-// 		}
-// 		rr.rolesDeleted++
-// 	}
-
-// 	existingRoleBindings := map[string]rbacv1.RoleBinding{}
-// 	roleBindingList := rbacv1.RoleBindingList{}
-// 	roleBindingsToDelete := []rbacv1.RoleBinding{}
-// 	err = c.crClient.List(ctx, &roleBindingList, listOption)
-// 	if err != nil {
-// 		c.log.Error(err, "error listing RoleBindings")
-// 		return err
-// 	}
-
-// 	for _, rb := range roleBindingList.Items {
-// 		_, isExisting := existingRoleBindings[rb.Namespace]
-// 		desiredRole, isDesired := desiredRoles[rb.Namespace]
-
-// 		// We want at most one RoleBinding per ClusterReferenceGrant and
-// 		// Namespace, anything beyond that should be deleted. We also can't
-// 		// change the RoleRef on an existing RoleBinding.
-// 		if !isExisting && isDesired && rb.RoleRef.Name == desiredRole.Name {
-// 			existingRoleBindings[rb.Namespace] = rb
-// 		} else {
-// 			roleBindingsToDelete = append(roleBindingsToDelete, rb)
-// 		}
-// 	}
-
-// 	for ns, roleName := range namespaceRoleNames {
-// 		rb := rbacv1.RoleBinding{
-// 			ObjectMeta: metav1.ObjectMeta{
-// 				Namespace: ns,
-// 				Labels:    map[string]string{labelKeyPatternName: crp.Name},
-// 			},
-// 			Subjects: subjects,
-// 			RoleRef: rbacv1.RoleRef{
-// 				APIGroup: rbacv1.SchemeGroupVersion.Group,
-// 				Kind:     "Role",
-// 				Name:     roleName,
-// 			},
-// 		}
-// 		if existingRB, ok := existingRoleBindings[rb.Namespace]; ok {
-// 			c.log.Info("Updating RoleBinding", "RoleBinding", rb)
-// 			rb.Name = existingRB.Name
-// 			err := c.crClient.Update(ctx, &rb)
-// 			if err != nil {
-// 				c.log.Error(err, "error updating RoleBinding")
-// 				return err
-// 			}
-// 			rr.roleBindingsUpdated++
-// 		} else {
-// 			rb.GenerateName = fmt.Sprintf("%s-", crp.Name)
-// 			c.log.Info("Creating RoleBinding", "RoleBinding", rb)
-// 			err := c.crClient.Create(ctx, &rb)
-// 			if err != nil {
-// 				c.log.Error(err, "error creating RoleBinding")
-// 				return err
-// 			}
-// 			rr.roleBindingsCreated++
-// 		}
-// 	}
-
-// 	for _, rbtd := range roleBindingsToDelete {
-// 		c.log.Info("Deleting RoleBinding", "RoleBinding", rbtd)
-// 		err := c.crClient.Delete(ctx, &rbtd)
-// 		if err != nil {
-// 			c.log.Error(err, "error deleting RoleBinding")
-// 			return err
-// 		}
-// 		rr.roleBindingsDeleted++
-// 	}
-
-// 	c.log.Info("Completed RBAC Reconciliation", "Results", fmt.Sprintf("%+v", rr))
-
-// 	return nil
-// }
-// func (c *Controller) getResourcesFor(ctx context.Context, forReason string) ([]v1a1.ReferenceGrant, []v1a1.ClusterReferenceGrant, []v1a1.ClusterReferenceConsumer, error) {
-// 	rgList := &v1a1.ReferenceGrantList{}
-// 	err := c.crClient.List(ctx, rgList)
-// 	if err != nil {
-// 		c.log.Error(err, "could not list ReferenceGrants")
-// 		return nil, nil, nil, err
-// 	}
-// 	referenceGrants := []v1a1.ReferenceGrant{}
-// 	for _, rg := range rgList.Items {
-// 		if string(rg.For) == forReason {
-// 			referenceGrants = append(referenceGrants, rg)
-// 		}
-// 	}
-
-// 	crgList := &v1a1.ClusterReferenceGrantList{}
-// 	err = c.crClient.List(ctx, rgList)
-// 	if err != nil {
-// 		c.log.Error(err, "could not list ClusterReferenceGrants")
-// 		return nil, nil, nil, err
-// 	}
-// 	clusterReferenceGrants := []v1a1.ClusterReferenceGrant{}
-// 	for _, crg := range crgList.Items {
-// 		if string(crg.For) == forReason {
-// 			clusterReferenceGrants = append(clusterReferenceGrants, crg)
-// 		}
-// 	}
-
-// 	crcList := &v1a1.ClusterReferenceConsumerList{}
-// 	err = c.crClient.List(ctx, crcList)
-// 	if err != nil {
-// 		c.log.Error(err, "could not list ClusterReferenceConsumers")
-// 		return nil, nil, nil, err
-// 	}
-// 	clusterReferenceConsumers := []v1a1.ClusterReferenceConsumer{}
-// 	for _, crc := range crcList.Items {
-// 		if string(crc.For) == forReason {
-// 			clusterReferenceConsumers = append(clusterReferenceConsumers, crc)
-// 		}
-// 	}
-
-// 	return rgList.Items, crgList.Items, crcList.Items, nil
-// }
-
-// func generateQueueKey(fromGroup, fromResource, toGroup, toResource, forReason string) types.NamespacedName {
-// 	nn := types.NamespacedName{Name: forReason}
-// 	nn.Namespace = fmt.Sprintf("%s/%s-%s/%s")
-// 	return nn
-// }
-
-// func parseQueueKey(nn types.NamespacedName) (string, string, string, string, string) {
-// 	fromTo := strings.Split(nn.Namespace, "-")
-// 	from := fromTo[0]
-// 	fromGR := strings.Split(from, "/")
-// 	fromGroup := fromGR[0]
-// 	fromResource := fromGR[1]
-// 	to := fromTo[1]
-// 	toGR := strings.Split(to, "/")
-// 	toGroup := toGR[0]
-// 	toResource := toGR[1]
-
-// 	return fromGroup, fromResource, toGroup, toResource, nn.Name
-// }
+// rbacSubjects converts graph subjects to sorted RBAC subjects. ServiceAccounts
+// are already normalized to their "system:serviceaccount:" User names in the
+// graph, which RBAC matches like any other User.
+func rbacSubjects(subjects sets.Set[v1a1.Subject]) []rbacv1.Subject {
+	out := make([]rbacv1.Subject, 0, subjects.Len())
+	for s := range subjects {
+		out = append(out, rbacv1.Subject{
+			APIGroup:  rbacv1.GroupName,
+			Kind:      s.Kind,
+			Name:      s.Name,
+			Namespace: s.Namespace,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind < out[j].Kind
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
@@ -0,0 +1,576 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+	"sigs.k8s.io/referencegrant-poc/pkg/graph"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+const gatewaySecretsKey = "gateway.networking.k8s.io/gateways;/secrets;tls-serving"
+
+var (
+	alice = v1a1.Subject{Kind: "User", Name: "alice"}
+	bob   = v1a1.Subject{Kind: "User", Name: "bob"}
+)
+
+func newFakeClient(t *testing.T, objs ...client.Object) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := rbacv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1a1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&v1a1.ClusterReferenceGrant{}).
+		Build()
+}
+
+func owner(name string, refs ...v1a1.ReferencePath) v1a1.ClusterReferenceGrant {
+	return v1a1.ClusterReferenceGrant{
+		ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name + "-uid")},
+		From:       v1a1.GroupResource{Group: "gateway.networking.k8s.io", Resource: "gateways"},
+		Versions:   []v1a1.VersionedReferencePaths{{Version: "v1", References: refs}},
+	}
+}
+
+var secretsReference = v1a1.ReferencePath{To: v1a1.GroupResource{Resource: "secrets"}, For: "tls-serving"}
+
+// grantedTargets returns the targets the generated Roles and RoleBindings
+// allow subject to get.
+func grantedTargets(t *testing.T, c client.Client, subject v1a1.Subject) sets.Set[types.NamespacedName] {
+	t.Helper()
+	ctx := context.Background()
+	bindings := &rbacv1.RoleBindingList{}
+	if err := c.List(ctx, bindings); err != nil {
+		t.Fatal(err)
+	}
+	granted := sets.New[types.NamespacedName]()
+	for _, rb := range bindings.Items {
+		bound := false
+		for _, s := range rb.Subjects {
+			if s.Kind == subject.Kind && s.Name == subject.Name {
+				bound = true
+			}
+		}
+		if !bound {
+			continue
+		}
+		role := &rbacv1.Role{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: rb.Namespace, Name: rb.RoleRef.Name}, role); err != nil {
+			t.Fatal(err)
+		}
+		for _, rule := range role.Rules {
+			for _, name := range rule.ResourceNames {
+				granted.Insert(types.NamespacedName{Namespace: rb.Namespace, Name: name})
+			}
+		}
+	}
+	return granted
+}
+
+func TestReconcileKeySubjectsPerTarget(t *testing.T) {
+	crg := owner("gateways", secretsReference)
+	c := newFakeClient(t, &crg)
+	r := NewReconciler(c, logr.Discard())
+
+	grants := map[types.NamespacedName]sets.Set[v1a1.Subject]{
+		{Namespace: "demo", Name: "x"}: sets.New(alice),
+		{Namespace: "demo", Name: "y"}: sets.New(bob),
+		{Namespace: "demo", Name: "z"}: sets.New(alice, bob),
+	}
+	if err := r.ReconcileKey(context.Background(), gatewaySecretsKey, grants, []v1a1.ClusterReferenceGrant{crg}); err != nil {
+		t.Fatalf("ReconcileKey() error = %v", err)
+	}
+
+	want := map[v1a1.Subject][]types.NamespacedName{
+		alice: {{Namespace: "demo", Name: "x"}, {Namespace: "demo", Name: "z"}},
+		bob:   {{Namespace: "demo", Name: "y"}, {Namespace: "demo", Name: "z"}},
+	}
+	for subject, targets := range want {
+		if diff := cmp.Diff(targets, sortedKeys(grantedTargets(t, c, subject))); diff != "" {
+			t.Errorf("ReconcileKey() targets of %s mismatch (-want +got):\n%s", subject.Name, diff)
+		}
+	}
+}
+
+// gateway returns a Gateway referencing a Secret in namespace "shared".
+func gateway(namespace, secret string) unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "gateway.networking.k8s.io/v1",
+		"kind":       "Gateway",
+		"metadata":   map[string]interface{}{"namespace": namespace, "name": "gw"},
+		"spec": map[string]interface{}{
+			"listeners": []interface{}{map[string]interface{}{
+				"name": "https",
+				"tls": map[string]interface{}{"certificateRefs": []interface{}{
+					map[string]interface{}{"kind": "Secret", "namespace": "shared", "name": secret},
+				}},
+			}},
+		},
+	}}
+}
+
+// reconcileSharedSecrets reconciles the graph of Gateways in namespaces "a"
+// and "b", each referencing its own Secret in namespace "shared", as allowed
+// by ReferenceGrants, for the consumers crcs and rcs.
+func reconcileSharedSecrets(t *testing.T, crcs []v1a1.ClusterReferenceConsumer, rcs []v1a1.ReferenceConsumer) client.Client {
+	t.Helper()
+	crg := owner("gateways", v1a1.ReferencePath{
+		Path: "$.spec.listeners[*].tls.certificateRefs[*]",
+		To:   v1a1.GroupResource{Resource: "secrets"},
+		For:  "tls-serving",
+	})
+	rgs := []v1a1.ReferenceGrant{}
+	for _, ns := range []string{"a", "b"} {
+		rgs = append(rgs, v1a1.ReferenceGrant{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shared", Name: ns},
+			From:       v1a1.GroupResourceNamespace{Group: crg.From.Group, Resource: crg.From.Resource, Namespace: ns},
+			To:         v1a1.ReferenceGrantTo{Resource: "secrets", Names: []string{ns + "-tls"}},
+			For:        "tls-serving",
+		})
+	}
+	objects := []unstructured.Unstructured{gateway("a", "a-tls"), gateway("b", "b-tls")}
+
+	kg, err := graph.ComputeKey(gatewaySecretsKey, false, crcs, rcs, []v1a1.ClusterReferenceGrant{crg}, rgs, objects)
+	if err != nil {
+		t.Fatalf("ComputeKey() error = %v", err)
+	}
+	s := store.NewAuthStore()
+	s.ReplaceGraphKey(kg.Key, kg.Edges, kg.Provenance)
+
+	c := newFakeClient(t, &crg)
+	r := NewReconciler(c, logr.Discard())
+	if err := r.ReconcileKey(context.Background(), gatewaySecretsKey, s.GetGraphKey(gatewaySecretsKey), []v1a1.ClusterReferenceGrant{crg}); err != nil {
+		t.Fatalf("ReconcileKey() error = %v", err)
+	}
+	return c
+}
+
+// checkSharedSecrets checks that the gateway ServiceAccount of namespaces
+// "a" and "b" is only granted the Secret referenced from its namespace.
+func checkSharedSecrets(t *testing.T, c client.Client) {
+	t.Helper()
+	for _, ns := range []string{"a", "b"} {
+		subject := v1a1.Subject{Kind: "User", Name: "system:serviceaccount:" + ns + ":gateway"}
+		want := []types.NamespacedName{{Namespace: "shared", Name: ns + "-tls"}}
+		if diff := cmp.Diff(want, sortedKeys(grantedTargets(t, c, subject))); diff != "" {
+			t.Errorf("ReconcileKey() targets of %s mismatch (-want +got):\n%s", subject.Name, diff)
+		}
+	}
+}
+
+func TestReconcileKeyTemplatedSubjects(t *testing.T) {
+	crcs := []v1a1.ClusterReferenceConsumer{{
+		ObjectMeta: metav1.ObjectMeta{Name: "gateway"},
+		Subject:    v1a1.Subject{Kind: "ServiceAccount", Namespace: "{{.FromNamespace}}", Name: "gateway"},
+		References: []v1a1.ConsumerReference{{
+			From: v1a1.GroupResource{Group: "gateway.networking.k8s.io", Resource: "gateways"},
+			To:   v1a1.GroupResource{Resource: "secrets"},
+			For:  "tls-serving",
+		}},
+	}}
+	checkSharedSecrets(t, reconcileSharedSecrets(t, crcs, nil))
+}
+
+func TestReconcileKeyReferenceConsumers(t *testing.T) {
+	rcs := []v1a1.ReferenceConsumer{}
+	for _, ns := range []string{"a", "b"} {
+		rcs = append(rcs, v1a1.ReferenceConsumer{
+			ObjectMeta:         metav1.ObjectMeta{Namespace: ns, Name: "ingress"},
+			ServiceAccountName: "gateway",
+			References: []v1a1.ReferenceConsumerReference{{
+				From: v1a1.GroupResource{Group: "gateway.networking.k8s.io", Resource: "gateways"},
+				To:   v1a1.GroupResource{Resource: "secrets"},
+				For:  "tls-serving",
+			}},
+		})
+	}
+	checkSharedSecrets(t, reconcileSharedSecrets(t, nil, rcs))
+}
+
+func TestObjectName(t *testing.T) {
+	aliceSubjects := rbacSubjects(sets.New(alice))
+	bothSubjects := rbacSubjects(sets.New(bob, alice))
+	longKey := "example.com/" + strings.Repeat("a", maxNameLength) + ";/secrets;tls-serving"
+	otherKey := "gateway.networking.k8s.io/gateways;/configmaps;parameters"
+
+	name := ObjectName(gatewaySecretsKey, aliceSubjects)
+	prefix := "reference.authorization.k8s.io:gateways.gateway.networking.k8s.io:secrets:tls-serving:"
+	if suffix, ok := strings.CutPrefix(name, prefix); !ok || len(suffix) != len("00000000") {
+		t.Errorf("ObjectName() = %q, want %q followed by a hash", name, prefix)
+	}
+	if other := ObjectName(gatewaySecretsKey, bothSubjects); other == name {
+		t.Errorf("ObjectName() = %q for different subjects", other)
+	}
+	if again := ObjectName(gatewaySecretsKey, rbacSubjects(sets.New(alice, bob))); again != ObjectName(gatewaySecretsKey, bothSubjects) {
+		t.Errorf("ObjectName() = %q, want the same name for the same subjects", again)
+	}
+	long := ObjectName(longKey, aliceSubjects)
+	if !strings.HasPrefix(long, namePrefix+PatternName(longKey)+":") || len(long) > maxNameLength {
+		t.Errorf("ObjectName() = %q, want the pattern name of a long key", long)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		obj  string
+		want bool
+	}{
+		{name: "generated", key: gatewaySecretsKey, obj: name, want: true},
+		{name: "generated for a long key", key: longKey, obj: long, want: true},
+		{name: "without subjects", key: gatewaySecretsKey, obj: keyName(gatewaySecretsKey), want: false},
+		{name: "other key", key: otherKey, obj: name, want: false},
+		{name: "longer suffix", key: gatewaySecretsKey, obj: name + "0", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isObjectName(tt.key, tt.obj); got != tt.want {
+				t.Errorf("isObjectName(%q, %q) = %v, want %v", tt.key, tt.obj, got, tt.want)
+			}
+		})
+	}
+}
+
+// generatedMeta returns the metadata of a Role or RoleBinding generated for
+// key and owned by owners.
+func generatedMeta(namespace, name, key string, owners ...v1a1.ClusterReferenceGrant) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace:       namespace,
+		Name:            name,
+		Labels:          map[string]string{LabelKeyPatternName: PatternName(key)},
+		Annotations:     map[string]string{AnnotationKeyPattern: key},
+		OwnerReferences: ownerReferencesFor(owners),
+	}
+}
+
+func role(meta metav1.ObjectMeta, names ...string) *rbacv1.Role {
+	return &rbacv1.Role{
+		ObjectMeta: meta,
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups:     []string{""},
+			Resources:     []string{"secrets"},
+			Verbs:         baseVerbs,
+			ResourceNames: names,
+		}},
+	}
+}
+
+func roleBinding(meta metav1.ObjectMeta, roleName string, subjects ...v1a1.Subject) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: meta,
+		Subjects:   rbacSubjects(sets.New(subjects...)),
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: roleName},
+	}
+}
+
+// rbacState returns the ResourceNames of the Roles and the subjects of the
+// RoleBindings in c, by namespace and name.
+func rbacState(t *testing.T, c client.Client) (map[types.NamespacedName][]string, map[types.NamespacedName][]string) {
+	t.Helper()
+	ctx := context.Background()
+	roles := &rbacv1.RoleList{}
+	if err := c.List(ctx, roles); err != nil {
+		t.Fatal(err)
+	}
+	gotRoles := map[types.NamespacedName][]string{}
+	for _, r := range roles.Items {
+		names := []string{}
+		for _, rule := range r.Rules {
+			names = append(names, rule.ResourceNames...)
+		}
+		gotRoles[types.NamespacedName{Namespace: r.Namespace, Name: r.Name}] = names
+	}
+	bindings := &rbacv1.RoleBindingList{}
+	if err := c.List(ctx, bindings); err != nil {
+		t.Fatal(err)
+	}
+	gotBindings := map[types.NamespacedName][]string{}
+	for _, rb := range bindings.Items {
+		subjects := []string{}
+		for _, subject := range rb.Subjects {
+			subjects = append(subjects, subject.Name)
+		}
+		gotBindings[types.NamespacedName{Namespace: rb.Namespace, Name: rb.Name}] = subjects
+	}
+	return gotRoles, gotBindings
+}
+
+func TestReconcileKey(t *testing.T) {
+	crg := owner("gateways", secretsReference)
+	aliceName := ObjectName(gatewaySecretsKey, rbacSubjects(sets.New(alice)))
+	bobName := ObjectName(gatewaySecretsKey, rbacSubjects(sets.New(bob)))
+	nn := func(namespace, name string) types.NamespacedName {
+		return types.NamespacedName{Namespace: namespace, Name: name}
+	}
+
+	tests := []struct {
+		name         string
+		existing     []client.Object
+		grants       map[types.NamespacedName]sets.Set[v1a1.Subject]
+		owners       []v1a1.ClusterReferenceGrant
+		wantRoles    map[types.NamespacedName][]string
+		wantBindings map[types.NamespacedName][]string
+		wantResults  []v1a1.ReconciliationResults
+	}{
+		{
+			name: "creates one Role and RoleBinding per namespace",
+			grants: map[types.NamespacedName]sets.Set[v1a1.Subject]{
+				nn("demo", "x"):  sets.New(alice),
+				nn("demo", "y"):  sets.New(alice),
+				nn("other", "z"): sets.New(alice),
+			},
+			owners:       []v1a1.ClusterReferenceGrant{crg},
+			wantRoles:    map[types.NamespacedName][]string{nn("demo", aliceName): {"x", "y"}, nn("other", aliceName): {"z"}},
+			wantBindings: map[types.NamespacedName][]string{nn("demo", aliceName): {"alice"}, nn("other", aliceName): {"alice"}},
+			wantResults: []v1a1.ReconciliationResults{{
+				Resource: "secrets", For: "tls-serving", Roles: 2, RoleBindings: 2,
+				RolesCreated: 2, RoleBindingsCreated: 2,
+			}},
+		},
+		{
+			name: "updates drifted and deletes stale objects",
+			existing: []client.Object{
+				role(generatedMeta("demo", aliceName, gatewaySecretsKey, crg), "x", "stale"),
+				roleBinding(generatedMeta("demo", aliceName, gatewaySecretsKey, crg), aliceName, alice),
+				role(generatedMeta("demo", bobName, gatewaySecretsKey, crg), "y"),
+				roleBinding(generatedMeta("demo", bobName, gatewaySecretsKey, crg), bobName, bob),
+			},
+			grants:       map[types.NamespacedName]sets.Set[v1a1.Subject]{nn("demo", "x"): sets.New(alice)},
+			owners:       []v1a1.ClusterReferenceGrant{crg},
+			wantRoles:    map[types.NamespacedName][]string{nn("demo", aliceName): {"x"}},
+			wantBindings: map[types.NamespacedName][]string{nn("demo", aliceName): {"alice"}},
+			wantResults: []v1a1.ReconciliationResults{{
+				Resource: "secrets", For: "tls-serving", Roles: 1, RoleBindings: 1,
+				RolesUpdated: 1, RolesDeleted: 1, RoleBindingsDeleted: 1,
+			}},
+		},
+		{
+			name: "recreates RoleBindings referring to another Role",
+			existing: []client.Object{
+				role(generatedMeta("demo", aliceName, gatewaySecretsKey, crg), "x"),
+				roleBinding(generatedMeta("demo", aliceName, gatewaySecretsKey, crg), bobName, alice),
+			},
+			grants:       map[types.NamespacedName]sets.Set[v1a1.Subject]{nn("demo", "x"): sets.New(alice)},
+			owners:       []v1a1.ClusterReferenceGrant{crg},
+			wantRoles:    map[types.NamespacedName][]string{nn("demo", aliceName): {"x"}},
+			wantBindings: map[types.NamespacedName][]string{nn("demo", aliceName): {"alice"}},
+			wantResults: []v1a1.ReconciliationResults{{
+				Resource: "secrets", For: "tls-serving", Roles: 1, RoleBindings: 1,
+				RoleBindingsCreated: 1, RoleBindingsDeleted: 1,
+			}},
+		},
+		{
+			name: "nothing without owners",
+			existing: []client.Object{
+				role(generatedMeta("demo", aliceName, gatewaySecretsKey, crg), "x"),
+				roleBinding(generatedMeta("demo", aliceName, gatewaySecretsKey, crg), aliceName, alice),
+			},
+			grants:       map[types.NamespacedName]sets.Set[v1a1.Subject]{nn("demo", "x"): sets.New(alice)},
+			wantRoles:    map[types.NamespacedName][]string{},
+			wantBindings: map[types.NamespacedName][]string{},
+		},
+		{
+			name:         "nothing for cluster-scoped targets",
+			grants:       map[types.NamespacedName]sets.Set[v1a1.Subject]{nn("", "x"): sets.New(alice)},
+			owners:       []v1a1.ClusterReferenceGrant{crg},
+			wantRoles:    map[types.NamespacedName][]string{},
+			wantBindings: map[types.NamespacedName][]string{},
+			wantResults:  []v1a1.ReconciliationResults{{Resource: "secrets", For: "tls-serving"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crg := crg.DeepCopy()
+			c := newFakeClient(t, append(tt.existing, crg)...)
+			r := NewReconciler(c, logr.Discard())
+			if err := r.ReconcileKey(context.Background(), gatewaySecretsKey, tt.grants, tt.owners); err != nil {
+				t.Fatalf("ReconcileKey() error = %v", err)
+			}
+
+			gotRoles, gotBindings := rbacState(t, c)
+			if diff := cmp.Diff(tt.wantRoles, gotRoles); diff != "" {
+				t.Errorf("ReconcileKey() Roles mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantBindings, gotBindings); diff != "" {
+				t.Errorf("ReconcileKey() RoleBindings mismatch (-want +got):\n%s", diff)
+			}
+			got := &v1a1.ClusterReferenceGrant{}
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(crg), got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.wantResults, got.Status.ReconciliationResults, ignoreChangeTime); diff != "" {
+				t.Errorf("ReconcileKey() results mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+var ignoreChangeTime = cmpopts.IgnoreFields(v1a1.ReconciliationResults{}, "LastChangeTime")
+
+func TestCleanupOrphans(t *testing.T) {
+	crg := owner("gateways", secretsReference)
+	otherKey := "gateway.networking.k8s.io/gateways;/configmaps;parameters"
+	kept := ObjectName(gatewaySecretsKey, rbacSubjects(sets.New(alice)))
+	unannotated := generatedMeta("demo", kept+"-unannotated", gatewaySecretsKey, crg)
+	unannotated.Annotations = nil
+	unrelated := metav1.ObjectMeta{Namespace: "demo", Name: "unrelated"}
+
+	metas := []metav1.ObjectMeta{
+		generatedMeta("demo", kept, gatewaySecretsKey, crg),
+		generatedMeta("demo", ObjectName(otherKey, rbacSubjects(sets.New(alice))), otherKey, crg),
+		generatedMeta("demo", keyName(gatewaySecretsKey), gatewaySecretsKey, crg),
+		unannotated,
+		unrelated,
+	}
+	objs := []client.Object{}
+	for _, meta := range metas {
+		objs = append(objs, role(meta, "x"), roleBinding(meta, meta.Name, alice))
+	}
+	c := newFakeClient(t, objs...)
+	r := NewReconciler(c, logr.Discard())
+	if err := r.CleanupOrphans(context.Background(), sets.New(gatewaySecretsKey)); err != nil {
+		t.Fatalf("CleanupOrphans() error = %v", err)
+	}
+
+	gotRoles, gotBindings := rbacState(t, c)
+	wantRoles := map[types.NamespacedName][]string{
+		{Namespace: "demo", Name: kept}:        {"x"},
+		{Namespace: "demo", Name: "unrelated"}: {"x"},
+	}
+	if diff := cmp.Diff(wantRoles, gotRoles); diff != "" {
+		t.Errorf("CleanupOrphans() Roles mismatch (-want +got):\n%s", diff)
+	}
+	wantBindings := map[types.NamespacedName][]string{
+		{Namespace: "demo", Name: kept}:        {"alice"},
+		{Namespace: "demo", Name: "unrelated"}: {"alice"},
+	}
+	if diff := cmp.Diff(wantBindings, gotBindings); diff != "" {
+		t.Errorf("CleanupOrphans() RoleBindings mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestOwnerReferencesFor(t *testing.T) {
+	got := ownerReferencesFor([]v1a1.ClusterReferenceGrant{owner("b"), owner("a")})
+	want := []metav1.OwnerReference{
+		{APIVersion: "reference.authorization.k8s.io/v1alpha1", Kind: "ClusterReferenceGrant", Name: "a", UID: "a-uid"},
+		{APIVersion: "reference.authorization.k8s.io/v1alpha1", Kind: "ClusterReferenceGrant", Name: "b", UID: "b-uid"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ownerReferencesFor() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestUpdateStatus(t *testing.T) {
+	configMapsReference := v1a1.ReferencePath{To: v1a1.GroupResource{Resource: "configmaps"}, For: "parameters"}
+	secrets := v1a1.ReconciliationResults{Resource: "secrets", For: "tls-serving", Roles: 1, RoleBindings: 1}
+	configMaps := v1a1.ReconciliationResults{Resource: "configmaps", For: "parameters", Roles: 3, RoleBindings: 3}
+	services := v1a1.ReconciliationResults{Resource: "services", For: "backend", Roles: 2, RoleBindings: 2}
+
+	tests := []struct {
+		name        string
+		results     []v1a1.ReconciliationResults
+		roles       int32
+		rr          reconciliationResults
+		want        []v1a1.ReconciliationResults
+		wantWritten bool
+	}{
+		{
+			name:    "records changes",
+			results: []v1a1.ReconciliationResults{secrets, configMaps},
+			roles:   2,
+			rr:      reconciliationResults{rolesCreated: 1, roleBindingsCreated: 1},
+			want: []v1a1.ReconciliationResults{
+				{Resource: "secrets", For: "tls-serving", Roles: 2, RoleBindings: 2, RolesCreated: 1, RoleBindingsCreated: 1},
+				configMaps,
+			},
+			wantWritten: true,
+		},
+		{
+			name:        "adds the reference",
+			results:     []v1a1.ReconciliationResults{configMaps},
+			roles:       1,
+			want:        []v1a1.ReconciliationResults{configMaps, secrets},
+			wantWritten: true,
+		},
+		{
+			name:        "prunes references that are no longer defined",
+			results:     []v1a1.ReconciliationResults{services, secrets, configMaps},
+			roles:       1,
+			want:        []v1a1.ReconciliationResults{secrets, configMaps},
+			wantWritten: true,
+		},
+		{
+			name:    "doesn't write an unchanged status",
+			results: []v1a1.ReconciliationResults{secrets, configMaps},
+			roles:   1,
+			want:    []v1a1.ReconciliationResults{secrets, configMaps},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crg := owner("gateways", secretsReference, configMapsReference)
+			crg.Status.ReconciliationResults = tt.results
+			c := newFakeClient(t, &crg)
+			before := &v1a1.ClusterReferenceGrant{}
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(&crg), before); err != nil {
+				t.Fatal(err)
+			}
+
+			r := NewReconciler(c, logr.Discard())
+			if err := r.updateStatus(context.Background(), []v1a1.ClusterReferenceGrant{*before}, "", "secrets", "tls-serving", tt.roles, tt.rr); err != nil {
+				t.Fatalf("updateStatus() error = %v", err)
+			}
+
+			got := &v1a1.ClusterReferenceGrant{}
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(&crg), got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got.Status.ReconciliationResults, ignoreChangeTime); diff != "" {
+				t.Errorf("updateStatus() results mismatch (-want +got):\n%s", diff)
+			}
+			if written := got.ResourceVersion != before.ResourceVersion; written != tt.wantWritten {
+				t.Errorf("updateStatus() wrote the status = %v, want %v", written, tt.wantWritten)
+			}
+			for _, res := range got.Status.ReconciliationResults {
+				if res.Resource == "secrets" && tt.rr.changed() && res.LastChangeTime == nil {
+					t.Errorf("updateStatus() didn't set the last change time")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package server runs the HTTP servers of the authorizer as runnables of the
+// controller manager.
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/referencegrant-poc/pkg/config"
+)
+
+// New returns a server of handler configured by cfg. Its listener is opened
+// right away, so that configuration errors surface before anything starts.
+//
+// The server runs on every replica, regardless of leader election. When its
+// context is done, it stops accepting connections and waits up to
+// cfg.ShutdownTimeout for in-flight requests to complete.
+func New(name string, cfg config.ServerConfiguration, handler http.Handler) (*manager.Server, error) {
+	listener, err := net.Listen("tcp", cfg.BindAddress)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TLS != nil {
+		tlsConfig, err := tlsConfig(cfg.TLS)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	return &manager.Server{
+		Name:            name,
+		Server:          &http.Server{Handler: handler},
+		Listener:        listener,
+		ShutdownTimeout: &cfg.ShutdownTimeout.Duration,
+	}, nil
+}
+
+func tlsConfig(cfg *config.TLSConfiguration) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.ClientCAFile != "" {
+		caBundle, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		tlsConfig.ClientCAs = clientCAs
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/referencegrant-poc/pkg/config"
+)
+
+// TestShutdownDrainsInFlightRequests checks that a request in flight when
+// the context is done still completes.
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("drained"))
+	})
+	s, err := New("test", config.ServerConfiguration{
+		BindAddress:     "127.0.0.1:0",
+		ShutdownTimeout: metav1.Duration{Duration: 10 * time.Second},
+	}, handler)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() {
+		stopped <- s.Start(ctx)
+	}()
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result)
+	go func() {
+		resp, err := http.Get("http://" + s.Listener.Addr().String())
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+	select {
+	case err := <-stopped:
+		t.Fatalf("server stopped with a request in flight: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+
+	res := <-responses
+	if res.err != nil || res.body != "drained" {
+		t.Errorf("in-flight request = %q, %v, want %q", res.body, res.err, "drained")
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("Start() error = %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+)
+
+// Links of a justification chain, in the order they are checked.
+const (
+	LinkClusterReferenceGrant    = "ClusterReferenceGrant"
+	LinkClusterReferenceConsumer = "ClusterReferenceConsumer"
+	LinkReference                = "Reference"
+	LinkReferenceGrant           = "ReferenceGrant"
+	LinkReferencePolicy          = "ReferencePolicy"
+)
+
+// Explanation describes why a subject is or isn't authorized to access a
+// target.
+type Explanation struct {
+	Allowed    bool  `json:"allowed"`
+	Generation int64 `json:"generation"`
+	// Chains are the from-to-for keys granting access to the target. They
+	// are only set when Allowed.
+	Chains []Chain `json:"chains,omitempty"`
+	// MissingLinks name, for every from-to-for key that could have granted
+	// access to the target, the first link of the chain that is missing.
+	MissingLinks []MissingLink `json:"missingLinks,omitempty"`
+}
+
+// Chain is the full justification of an edge of the graph.
+type Chain struct {
+	Key string `json:"key"`
+	// ClusterReferenceConsumers made the subject a consumer of the key.
+	// ReferenceConsumers are named namespace/name.
+	ClusterReferenceConsumers []string `json:"clusterReferenceConsumers"`
+	// ClusterReferenceGrants define the reference paths of the key.
+	ClusterReferenceGrants []string `json:"clusterReferenceGrants"`
+	// Sources are the From objects referencing the target.
+	Sources []types.NamespacedName `json:"sources"`
+	// ReferenceGrants allow the cross-namespace references to the target.
+	ReferenceGrants []types.NamespacedName `json:"referenceGrants,omitempty"`
+}
+
+// MissingLink is the first link missing from the justification chain of a
+// from-to-for key.
+type MissingLink struct {
+	Key     string `json:"key,omitempty"`
+	Link    string `json:"link"`
+	Message string `json:"message"`
+}
+
+// Explain walks the graph and its provenance to explain why user is or isn't
+// authorized to access the named target.
+func (s *AuthStore) Explain(user, group, resource, namespace, name string) Explanation {
+	subject := v1a1.Subject{Kind: "User", Name: user}
+	trg := TargetResourceGroup(fmt.Sprintf("%s/%s", group, resource))
+	nn := types.NamespacedName{Namespace: namespace, Name: name}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	explanation := Explanation{Generation: s.generation}
+	candidates := []string{}
+	for key := range s.provenance {
+		if TargetResourceGroup(strings.Split(key, ";")[1]) == trg {
+			candidates = append(candidates, key)
+		}
+	}
+	sort.Strings(candidates)
+	if len(candidates) == 0 {
+		explanation.MissingLinks = append(explanation.MissingLinks, MissingLink{
+			Link:    LinkClusterReferenceGrant,
+			Message: fmt.Sprintf("no ClusterReferenceGrant defines references to %s", trg),
+		})
+		return explanation
+	}
+
+	for _, key := range candidates {
+		kp := s.provenance[key]
+		missing := func(link, format string, args ...interface{}) {
+			explanation.MissingLinks = append(explanation.MissingLinks, MissingLink{
+				Key:     key,
+				Link:    link,
+				Message: fmt.Sprintf(format, args...),
+			})
+		}
+		switch {
+		case kp.clusterReferenceGrants.Len() == 0:
+			missing(LinkClusterReferenceGrant, "no ClusterReferenceGrant defines a reference path for %s", key)
+		case kp.consumers[subject].Len() == 0:
+			missing(LinkClusterReferenceConsumer, "no ClusterReferenceConsumer or ReferenceConsumer makes %s a consumer of %s", user, key)
+		case s.graph[key][nn].Has(subject):
+			explanation.Chains = append(explanation.Chains, Chain{
+				Key:                       key,
+				ClusterReferenceConsumers: sets.List(kp.consumers[subject]),
+				ClusterReferenceGrants:    sets.List(kp.clusterReferenceGrants),
+				Sources:                   sortedNamespacedNames(kp.sources[nn]),
+				ReferenceGrants:           sortedNamespacedNames(kp.referenceGrants[nn]),
+			})
+		case kp.blocked[nn].Len() > 0:
+			missing(LinkReferencePolicy, "ReferencePolicies %v exclude %s from being granted", sets.List(kp.blocked[nn]), nn)
+		case kp.ungranted[nn].Len() > 0:
+			missing(LinkReferenceGrant, "no ReferenceGrant in namespace %s allows the references from %v", namespace, sortedNamespacedNames(kp.ungranted[nn]))
+		default:
+			missing(LinkReference, "no %s object references %s", strings.Split(key, ";")[0], nn)
+		}
+	}
+	explanation.Allowed = len(explanation.Chains) > 0
+	if explanation.Allowed {
+		explanation.MissingLinks = nil
+	}
+	return explanation
+}
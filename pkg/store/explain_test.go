@@ -0,0 +1,177 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"k8s.io/apimachinery/pkg/types"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+)
+
+const (
+	gatewaySecretsKey = "gateway.networking.k8s.io/gateways;/secrets;tls-serving"
+	podSecretsKey     = "/pods;/secrets;env"
+	backendsKey       = "gateway.networking.k8s.io/httproutes;/services;backend"
+)
+
+// explainStore returns a store where alice consumes gatewaySecretsKey and bob
+// podSecretsKey, and no ClusterReferenceGrant defines backendsKey anymore.
+func explainStore() *AuthStore {
+	alice := v1a1.Subject{Kind: "User", Name: "alice"}
+	bob := v1a1.Subject{Kind: "User", Name: "bob"}
+
+	s := NewAuthStore()
+	s.ReplaceGraphKey(gatewaySecretsKey,
+		[]Edge{
+			{Target: nn("demo", "tls"), Subjects: []v1a1.Subject{alice}, Sources: []types.NamespacedName{nn("demo", "gw")}},
+			{
+				Target:          nn("shared", "tls"),
+				Subjects:        []v1a1.Subject{alice},
+				Sources:         []types.NamespacedName{nn("other", "gw")},
+				ReferenceGrants: []types.NamespacedName{nn("shared", "allow-other")},
+			},
+		},
+		KeyProvenance{
+			Consumers:              map[v1a1.Subject][]string{alice: {"gateway-controller"}},
+			ClusterReferenceGrants: []string{"gateways"},
+			Ungranted:              []Edge{{Target: nn("shared", "ungranted"), Sources: []types.NamespacedName{nn("other", "gw")}}},
+			Blocked:                map[types.NamespacedName][]string{nn("kube-system", "token"): {"system"}},
+		})
+	s.ReplaceGraphKey(podSecretsKey, nil, KeyProvenance{
+		Consumers:              map[v1a1.Subject][]string{bob: {"demo/pods"}},
+		ClusterReferenceGrants: []string{"pods"},
+	})
+	s.ReplaceGraphKey(backendsKey, nil, KeyProvenance{})
+	return s
+}
+
+func nn(namespace, name string) types.NamespacedName {
+	return types.NamespacedName{Namespace: namespace, Name: name}
+}
+
+func TestExplain(t *testing.T) {
+	s := explainStore()
+
+	tests := []struct {
+		name      string
+		user      string
+		resource  string
+		target    types.NamespacedName
+		want      Explanation
+		wantLinks []MissingLink
+	}{
+		{
+			name:     "allowed clears the missing links of other keys",
+			user:     "alice",
+			resource: "secrets",
+			target:   nn("demo", "tls"),
+			want: Explanation{
+				Allowed: true,
+				Chains: []Chain{{
+					Key:                       gatewaySecretsKey,
+					ClusterReferenceConsumers: []string{"gateway-controller"},
+					ClusterReferenceGrants:    []string{"gateways"},
+					Sources:                   []types.NamespacedName{nn("demo", "gw")},
+				}},
+			},
+		},
+		{
+			name:     "allowed cross-namespace",
+			user:     "alice",
+			resource: "secrets",
+			target:   nn("shared", "tls"),
+			want: Explanation{
+				Allowed: true,
+				Chains: []Chain{{
+					Key:                       gatewaySecretsKey,
+					ClusterReferenceConsumers: []string{"gateway-controller"},
+					ClusterReferenceGrants:    []string{"gateways"},
+					Sources:                   []types.NamespacedName{nn("other", "gw")},
+					ReferenceGrants:           []types.NamespacedName{nn("shared", "allow-other")},
+				}},
+			},
+		},
+		{
+			name:      "no ClusterReferenceGrant for the resource",
+			user:      "alice",
+			resource:  "configmaps",
+			target:    nn("demo", "config"),
+			wantLinks: []MissingLink{{Link: LinkClusterReferenceGrant}},
+		},
+		{
+			name:      "no ClusterReferenceGrant for the key",
+			user:      "alice",
+			resource:  "services",
+			target:    nn("demo", "backend"),
+			wantLinks: []MissingLink{{Key: backendsKey, Link: LinkClusterReferenceGrant}},
+		},
+		{
+			name:     "not a consumer",
+			user:     "carol",
+			resource: "secrets",
+			target:   nn("demo", "tls"),
+			wantLinks: []MissingLink{
+				{Key: podSecretsKey, Link: LinkClusterReferenceConsumer},
+				{Key: gatewaySecretsKey, Link: LinkClusterReferenceConsumer},
+			},
+		},
+		{
+			name:     "no reference",
+			user:     "bob",
+			resource: "secrets",
+			target:   nn("demo", "tls"),
+			wantLinks: []MissingLink{
+				{Key: podSecretsKey, Link: LinkReference},
+				{Key: gatewaySecretsKey, Link: LinkClusterReferenceConsumer},
+			},
+		},
+		{
+			name:     "excluded by a ReferencePolicy",
+			user:     "alice",
+			resource: "secrets",
+			target:   nn("kube-system", "token"),
+			wantLinks: []MissingLink{
+				{Key: podSecretsKey, Link: LinkClusterReferenceConsumer},
+				{Key: gatewaySecretsKey, Link: LinkReferencePolicy},
+			},
+		},
+		{
+			name:     "no ReferenceGrant",
+			user:     "alice",
+			resource: "secrets",
+			target:   nn("shared", "ungranted"),
+			wantLinks: []MissingLink{
+				{Key: podSecretsKey, Link: LinkClusterReferenceConsumer},
+				{Key: gatewaySecretsKey, Link: LinkReferenceGrant},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.Explain(tt.user, "", tt.resource, tt.target.Namespace, tt.target.Name)
+			want := tt.want
+			want.Generation = s.Generation()
+			want.MissingLinks = tt.wantLinks
+			if diff := cmp.Diff(want, got, cmpopts.EquateEmpty(), cmpopts.IgnoreFields(MissingLink{}, "Message")); diff != "" {
+				t.Errorf("Explain() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+)
+
+// GraphFilter limits a GraphView. Zero fields match everything.
+type GraphFilter struct {
+	// Subject limits the view to edges granting access to the subject.
+	Subject *v1a1.Subject
+	// Key limits the view to a from-to-for key.
+	Key string
+	// Namespace limits the view to targets in the namespace.
+	Namespace string
+}
+
+// GraphView is a serializable copy of the graph and of its subject index.
+type GraphView struct {
+	Generation int64         `json:"generation"`
+	Keys       []KeyView     `json:"keys"`
+	Subjects   []SubjectView `json:"subjects"`
+}
+
+// KeyView holds the edges of a from-to-for key.
+type KeyView struct {
+	Key   string `json:"key"`
+	Edges []Edge `json:"edges"`
+}
+
+// SubjectView holds the targets a subject is allowed to access.
+type SubjectView struct {
+	Subject v1a1.Subject   `json:"subject"`
+	Grants  []SubjectGrant `json:"grants"`
+}
+
+// SubjectGrant is a target a subject is allowed to access, and the from-to-for
+// keys allowing it.
+type SubjectGrant struct {
+	Resource TargetResourceGroup  `json:"resource"`
+	Target   types.NamespacedName `json:"target"`
+	Keys     []string             `json:"keys"`
+}
+
+// View returns a copy of the graph and of its subject index, limited by filter.
+func (s *AuthStore) View(filter GraphFilter) GraphView {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	view := GraphView{Generation: s.generation, Keys: []KeyView{}, Subjects: []SubjectView{}}
+	for key, targets := range s.graph {
+		if filter.Key != "" && key != filter.Key {
+			continue
+		}
+		kv := KeyView{Key: key}
+		for target, subjects := range targets {
+			if filter.Namespace != "" && target.Namespace != filter.Namespace {
+				continue
+			}
+			if filter.Subject != nil && !subjects.Has(*filter.Subject) {
+				continue
+			}
+			kv.Edges = append(kv.Edges, Edge{
+				Target:          target,
+				Subjects:        sortedSubjects(subjects),
+				Sources:         sortedNamespacedNames(s.provenance[key].sources[target]),
+				ReferenceGrants: sortedNamespacedNames(s.provenance[key].referenceGrants[target]),
+			})
+		}
+		if len(kv.Edges) == 0 {
+			continue
+		}
+		sort.Slice(kv.Edges, func(i, j int) bool {
+			return lessNamespacedName(kv.Edges[i].Target, kv.Edges[j].Target)
+		})
+		view.Keys = append(view.Keys, kv)
+	}
+	sort.Slice(view.Keys, func(i, j int) bool { return view.Keys[i].Key < view.Keys[j].Key })
+
+	for subject, trgs := range s.subjectIndex {
+		if filter.Subject != nil && subject != *filter.Subject {
+			continue
+		}
+		sv := SubjectView{Subject: subject}
+		for trg, targets := range trgs {
+			for target, keys := range targets {
+				if filter.Namespace != "" && target.Namespace != filter.Namespace {
+					continue
+				}
+				if filter.Key != "" && !keys.Has(filter.Key) {
+					continue
+				}
+				sv.Grants = append(sv.Grants, SubjectGrant{Resource: trg, Target: target, Keys: sets.List(keys)})
+			}
+		}
+		if len(sv.Grants) == 0 {
+			continue
+		}
+		sort.Slice(sv.Grants, func(i, j int) bool {
+			if sv.Grants[i].Resource != sv.Grants[j].Resource {
+				return sv.Grants[i].Resource < sv.Grants[j].Resource
+			}
+			return lessNamespacedName(sv.Grants[i].Target, sv.Grants[j].Target)
+		})
+		view.Subjects = append(view.Subjects, sv)
+	}
+	sort.Slice(view.Subjects, func(i, j int) bool {
+		return lessSubject(view.Subjects[i].Subject, view.Subjects[j].Subject)
+	})
+	return view
+}
+
+func sortedSubjects(subjects sets.Set[v1a1.Subject]) []v1a1.Subject {
+	out := subjects.UnsortedList()
+	sort.Slice(out, func(i, j int) bool { return lessSubject(out[i], out[j]) })
+	return out
+}
+
+func lessSubject(a, b v1a1.Subject) bool {
+	if a.Kind != b.Kind {
+		return a.Kind < b.Kind
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
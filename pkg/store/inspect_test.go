@@ -0,0 +1,131 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"k8s.io/apimachinery/pkg/types"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+)
+
+func TestView(t *testing.T) {
+	alice := v1a1.Subject{Kind: "User", Name: "alice"}
+	bob := v1a1.Subject{Kind: "User", Name: "bob"}
+	secrets := TargetResourceGroup("/secrets")
+
+	s := NewAuthStore()
+	s.ReplaceGraphKey(gatewaySecretsKey,
+		[]Edge{
+			{Target: nn("demo", "tls"), Subjects: []v1a1.Subject{alice}, Sources: []types.NamespacedName{nn("demo", "gw")}},
+			{
+				Target:          nn("shared", "tls"),
+				Subjects:        []v1a1.Subject{bob, alice},
+				Sources:         []types.NamespacedName{nn("other", "gw")},
+				ReferenceGrants: []types.NamespacedName{nn("shared", "allow-other")},
+			},
+		},
+		KeyProvenance{})
+	s.ReplaceGraphKey(podSecretsKey,
+		[]Edge{{Target: nn("demo", "tls"), Subjects: []v1a1.Subject{bob}, Sources: []types.NamespacedName{nn("demo", "pod")}}},
+		KeyProvenance{})
+
+	demoGateway := Edge{Target: nn("demo", "tls"), Subjects: []v1a1.Subject{alice}, Sources: []types.NamespacedName{nn("demo", "gw")}}
+	sharedGateway := Edge{
+		Target:          nn("shared", "tls"),
+		Subjects:        []v1a1.Subject{alice, bob},
+		Sources:         []types.NamespacedName{nn("other", "gw")},
+		ReferenceGrants: []types.NamespacedName{nn("shared", "allow-other")},
+	}
+	demoPod := Edge{Target: nn("demo", "tls"), Subjects: []v1a1.Subject{bob}, Sources: []types.NamespacedName{nn("demo", "pod")}}
+
+	tests := []struct {
+		name   string
+		filter GraphFilter
+		want   GraphView
+	}{
+		{
+			name: "everything",
+			want: GraphView{
+				Keys: []KeyView{
+					{Key: podSecretsKey, Edges: []Edge{demoPod}},
+					{Key: gatewaySecretsKey, Edges: []Edge{demoGateway, sharedGateway}},
+				},
+				Subjects: []SubjectView{
+					{Subject: alice, Grants: []SubjectGrant{
+						{Resource: secrets, Target: nn("demo", "tls"), Keys: []string{gatewaySecretsKey}},
+						{Resource: secrets, Target: nn("shared", "tls"), Keys: []string{gatewaySecretsKey}},
+					}},
+					{Subject: bob, Grants: []SubjectGrant{
+						{Resource: secrets, Target: nn("demo", "tls"), Keys: []string{podSecretsKey}},
+						{Resource: secrets, Target: nn("shared", "tls"), Keys: []string{gatewaySecretsKey}},
+					}},
+				},
+			},
+		},
+		{
+			name:   "key",
+			filter: GraphFilter{Key: podSecretsKey},
+			want: GraphView{
+				Keys: []KeyView{{Key: podSecretsKey, Edges: []Edge{demoPod}}},
+				Subjects: []SubjectView{
+					{Subject: bob, Grants: []SubjectGrant{{Resource: secrets, Target: nn("demo", "tls"), Keys: []string{podSecretsKey}}}},
+				},
+			},
+		},
+		{
+			name:   "namespace",
+			filter: GraphFilter{Namespace: "shared"},
+			want: GraphView{
+				Keys: []KeyView{{Key: gatewaySecretsKey, Edges: []Edge{sharedGateway}}},
+				Subjects: []SubjectView{
+					{Subject: alice, Grants: []SubjectGrant{{Resource: secrets, Target: nn("shared", "tls"), Keys: []string{gatewaySecretsKey}}}},
+					{Subject: bob, Grants: []SubjectGrant{{Resource: secrets, Target: nn("shared", "tls"), Keys: []string{gatewaySecretsKey}}}},
+				},
+			},
+		},
+		{
+			name:   "subject",
+			filter: GraphFilter{Subject: &alice},
+			want: GraphView{
+				Keys: []KeyView{{Key: gatewaySecretsKey, Edges: []Edge{demoGateway, sharedGateway}}},
+				Subjects: []SubjectView{
+					{Subject: alice, Grants: []SubjectGrant{
+						{Resource: secrets, Target: nn("demo", "tls"), Keys: []string{gatewaySecretsKey}},
+						{Resource: secrets, Target: nn("shared", "tls"), Keys: []string{gatewaySecretsKey}},
+					}},
+				},
+			},
+		},
+		{
+			name:   "subject, key and namespace",
+			filter: GraphFilter{Subject: &bob, Key: gatewaySecretsKey, Namespace: "demo"},
+			want:   GraphView{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			want.Generation = s.Generation()
+			if diff := cmp.Diff(want, s.View(tt.filter), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("View() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -18,10 +18,12 @@ package store
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 
 	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
@@ -29,9 +31,16 @@ import (
 
 const ExtraPurposeKey = "purpose"
 
+// fieldSelectorKeyName is the field selector key that limits list and watch
+// requests to objects with a given name.
+const fieldSelectorKeyName = "metadata.name"
+
+// readVerbs are the only verbs references grant.
+var readVerbs = sets.New("get", "list", "watch")
+
 // AuthorizationStore interface
 type AuthorizationStore interface {
-	CheckAuthz(sar authorizationv1.SubjectAccessReview) (bool, error)
+	CheckAuthz(sar authorizationv1.SubjectAccessReview) (Decision, error)
 	// AddGrant(resource, verb string, allowed bool)
 }
 
@@ -44,6 +53,72 @@ type TargetResourceGroup string
 // This is the "For" string
 type Purpose string
 
+// Decision is the result of looking up a SubjectAccessReview in the graph.
+type Decision struct {
+	Allowed bool
+	// Purpose is the purpose access was granted for. It is only set when
+	// Allowed, and joins the purposes of all names a list or watch request is
+	// limited to.
+	Purpose Purpose
+	// Generation is the generation of the graph the decision was made on.
+	Generation int64
+	// Justifications describe why access to each target was allowed. They
+	// are only set when Allowed.
+	Justifications []Justification
+}
+
+// Justification describes a from-to-for key that granted access to a target,
+// and the From objects whose references to the target justify it.
+type Justification struct {
+	Key     string
+	Target  types.NamespacedName
+	Sources []types.NamespacedName
+}
+
+// Edge is a target of a from-to-for key, along with the subjects allowed to
+// access it, the From objects referencing it and, for cross-namespace
+// references, the ReferenceGrants allowing them.
+type Edge struct {
+	Target          types.NamespacedName   `json:"target"`
+	Subjects        []v1a1.Subject         `json:"subjects,omitempty"`
+	Sources         []types.NamespacedName `json:"sources,omitempty"`
+	ReferenceGrants []types.NamespacedName `json:"referenceGrants,omitempty"`
+}
+
+// KeyProvenance describes what the edges of a from-to-for key were computed
+// from, beyond the edges themselves.
+type KeyProvenance struct {
+	// Consumers maps subjects to the names of the ClusterReferenceConsumers,
+	// and namespace/names of the ReferenceConsumers, that made them consumers
+	// of the key.
+	Consumers map[v1a1.Subject][]string
+	// Subresources maps subjects to the subresources of the targets they
+	// are authorized for. Subjects are authorized for no subresource by
+	// default.
+	Subresources map[v1a1.Subject][]string
+	// ClusterReferenceGrants are the names of the ClusterReferenceGrants
+	// defining reference paths for the key.
+	ClusterReferenceGrants []string
+	// Ungranted are cross-namespace references that were dropped because no
+	// ReferenceGrant allows them. Their Subjects are not set.
+	Ungranted []Edge
+	// Blocked maps the targets whose edges were dropped because
+	// ReferencePolicies exclude them to the names of those policies.
+	Blocked map[types.NamespacedName][]string
+}
+
+// keyProvenance is the indexed form of KeyProvenance and the provenance of
+// the edges of a key.
+type keyProvenance struct {
+	consumers              map[v1a1.Subject]sets.Set[string]
+	subresources           map[v1a1.Subject]sets.Set[string]
+	clusterReferenceGrants sets.Set[string]
+	sources                map[types.NamespacedName]sets.Set[types.NamespacedName]
+	referenceGrants        map[types.NamespacedName]sets.Set[types.NamespacedName]
+	ungranted              map[types.NamespacedName]sets.Set[types.NamespacedName]
+	blocked                map[types.NamespacedName]sets.Set[string]
+}
+
 // Initial version of the graph - maps "from-to-for" to a map of target("to")resource names to set of subjects
 // This ignores classNames, namespace, verbs.
 type GrantGraph map[string]map[types.NamespacedName]sets.Set[v1a1.Subject]
@@ -51,35 +126,82 @@ type GrantGraph map[string]map[types.NamespacedName]sets.Set[v1a1.Subject]
 // in-memory AuthStore
 type AuthStore struct {
 	graph GrantGraph
-	// TODO: should we support multiple purposes for the same subject ?
-	subjectIndex map[v1a1.Subject]map[TargetResourceGroup]map[types.NamespacedName]Purpose
-	mutex        sync.RWMutex
+	// provenance maps "from-to-for" to what it was computed from.
+	provenance map[string]*keyProvenance
+	// subjectIndex maps subjects to the "from-to-for" keys granting them
+	// access to each target.
+	subjectIndex map[v1a1.Subject]map[TargetResourceGroup]map[types.NamespacedName]sets.Set[string]
+	// generation is incremented every time the graph changes.
+	generation int64
+	mutex      sync.RWMutex
 }
 
-func (s *AuthStore) GetGraph() GrantGraph {
-	return s.graph
+// GetGraphKey returns a copy of the grants computed for a from-to-for key.
+func (s *AuthStore) GetGraphKey(key string) map[types.NamespacedName]sets.Set[v1a1.Subject] {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	grants := make(map[types.NamespacedName]sets.Set[v1a1.Subject], len(s.graph[key]))
+	for nn, subjects := range s.graph[key] {
+		grants[nn] = subjects.Clone()
+	}
+	return grants
 }
 
-func (s *AuthStore) GetSubjectIndex() map[v1a1.Subject]map[TargetResourceGroup]map[types.NamespacedName]Purpose {
-	return s.subjectIndex
+// Stats returns the number of from-to-for keys, subject to target edges and
+// subjects in the graph.
+func (s *AuthStore) Stats() (keys, edges, subjects int) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, targets := range s.graph {
+		for _, subjs := range targets {
+			edges += subjs.Len()
+		}
+	}
+	return len(s.graph), edges, len(s.subjectIndex)
+}
+
+// Generation returns the current generation of the graph.
+func (s *AuthStore) Generation() int64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.generation
 }
 
 func NewAuthStore() *AuthStore {
 	return &AuthStore{
 		graph:        make(GrantGraph),
-		subjectIndex: make(map[v1a1.Subject]map[TargetResourceGroup]map[types.NamespacedName]Purpose),
+		provenance:   make(map[string]*keyProvenance),
+		subjectIndex: make(map[v1a1.Subject]map[TargetResourceGroup]map[types.NamespacedName]sets.Set[string]),
 		mutex:        sync.RWMutex{},
 	}
 }
 
-func (s *AuthStore) CheckAuthz(sar authorizationv1.SubjectAccessReview) (bool, error) {
+// CheckAuthz looks up a SubjectAccessReview in the graph. References only
+// grant read access, so verbs other than get, list and watch are never
+// allowed, as in rbac mode.
+//
+// Requests without a name (list and watch) are looked up once for every name
+// their metadata.name field selector limits them to, and are only allowed if
+// all of those names are granted.
+func (s *AuthStore) CheckAuthz(sar authorizationv1.SubjectAccessReview) (Decision, error) {
+	if !readVerbs.Has(sar.Spec.ResourceAttributes.Verb) {
+		return Decision{Generation: s.Generation()}, nil
+	}
 	user := sar.Spec.User
 	// groups := sar.Spec.Groups
 	trg := TargetResourceGroup(fmt.Sprintf("%s/%s", sar.Spec.ResourceAttributes.Group, sar.Spec.ResourceAttributes.Resource))
 
-	nn := types.NamespacedName{
-		Name:      sar.Spec.ResourceAttributes.Name,
-		Namespace: sar.Spec.ResourceAttributes.Namespace,
+	names := sets.New(sar.Spec.ResourceAttributes.Name)
+	if sar.Spec.ResourceAttributes.Name == "" {
+		// list and watch requests carry no name, but can still be authorized
+		// if a metadata.name field selector limits them to granted names.
+		var limited bool
+		names, limited = selectedNames(sar.Spec.ResourceAttributes)
+		if !limited {
+			return Decision{Generation: s.Generation()}, nil
+		}
 	}
 
 	// Not necessary for the demo.
@@ -96,8 +218,69 @@ func (s *AuthStore) CheckAuthz(sar authorizationv1.SubjectAccessReview) (bool, e
 	// 		return true, nil
 	// 	}
 	// }
-	allowed := s.lookup(v1a1.Subject{Kind: "User", Name: user}, trg, nn)
-	return allowed, nil
+	decision := Decision{Generation: s.generation}
+	purposes := sets.New[string]()
+	for _, name := range sets.List(names) {
+		nn := types.NamespacedName{
+			Name:      name,
+			Namespace: sar.Spec.ResourceAttributes.Namespace,
+		}
+		subject := v1a1.Subject{Kind: "User", Name: user}
+		keys, ok := s.lookup(subject, trg, nn)
+		if !ok {
+			return Decision{Generation: s.generation}, nil
+		}
+		if subresource := sar.Spec.ResourceAttributes.Subresource; subresource != "" {
+			keys = s.coveringSubresource(keys, subject, subresource)
+			if keys.Len() == 0 {
+				return Decision{Generation: s.generation}, nil
+			}
+		}
+		for _, key := range sets.List(keys) {
+			purposes.Insert(strings.Split(key, ";")[2])
+			decision.Justifications = append(decision.Justifications, Justification{
+				Key:     key,
+				Target:  nn,
+				Sources: sortedNamespacedNames(s.provenance[key].sources[nn]),
+			})
+		}
+	}
+	decision.Allowed = true
+	decision.Purpose = Purpose(strings.Join(sets.List(purposes), ","))
+	return decision, nil
+}
+
+// selectedNames returns the names a list or watch request is limited to by
+// its metadata.name field selector requirements. Requirements are ANDed, so
+// the request is limited to the intersection of all "In" requirements on
+// metadata.name. Other keys and operators can only narrow the request further
+// and are ignored. The raw selector is deliberately not parsed, as recommended
+// for webhook authorizers.
+//
+// The second return value is false if the request is not limited to a
+// non-empty set of names.
+func selectedNames(attrs *authorizationv1.ResourceAttributes) (sets.Set[string], bool) {
+	if attrs.Verb != "list" && attrs.Verb != "watch" {
+		return nil, false
+	}
+	if attrs.FieldSelector == nil {
+		return nil, false
+	}
+	var names sets.Set[string]
+	for _, req := range attrs.FieldSelector.Requirements {
+		if req.Key != fieldSelectorKeyName || req.Operator != metav1.FieldSelectorOpIn {
+			continue
+		}
+		if names == nil {
+			names = sets.New(req.Values...)
+		} else {
+			names = names.Intersection(sets.New(req.Values...))
+		}
+	}
+	if names.Len() == 0 {
+		return nil, false
+	}
+	return names, true
 }
 
 // Graph Lookup is perfomed as follows:
@@ -108,83 +291,165 @@ func (s *AuthStore) CheckAuthz(sar authorizationv1.SubjectAccessReview) (bool, e
 //  2. It then looks for the target resource group (trg) within the subject's map in the graph.
 //     If not found, it returns false.
 //
-//  3. Next, it looks for the namespacedName within the target resource group's map and return true or false accordingly.
-func (s *AuthStore) lookup(subj v1a1.Subject, trg TargetResourceGroup, nn types.NamespacedName) bool {
-	// if strings.HasPrefix(nn.Name, "demo") {
-	// 	// log.Printf("Attempting to lookup graph for with subj=%v, trg=%v, nn=%v, purpose=%v", subj, trg, nn, p)
-	// 	log.Printf("Attempting to lookup graph for with subj=%v, trg=%v, nn=%v", subj, trg, nn)
-	// 	log.Printf("SubjectIndex is: %v", s.GetSubjectIndex())
-	// }
+//  3. Next, it looks for the namespacedName within the target resource group's map and return true or false accordingly,
+//     along with the "from-to-for" keys that granted it.
+func (s *AuthStore) lookup(subj v1a1.Subject, trg TargetResourceGroup, nn types.NamespacedName) (sets.Set[string], bool) {
 	trgMap, ok := s.subjectIndex[subj]
 	if !ok {
-		return false
+		return nil, false
 	}
 	tnnMap, ok := trgMap[trg]
 	if !ok {
-		return false
+		return nil, false
 	}
 	// We don't care what is the purpose it is authorized as we currently have no way to get the "Purpose" or "For" from the client
-	_, ok = tnnMap[nn]
-	return ok
+	keys, ok := tnnMap[nn]
+	return keys, ok
 
 }
 
-func (s *AuthStore) UpsertGrant(key string, resourceName types.NamespacedName, subjects []v1a1.Subject) {
-	splitedKey := strings.Split(key, ";")
-	to, purpose := TargetResourceGroup(splitedKey[1]), Purpose(splitedKey[2])
-	// resourceNameFmt := TargetNamespacedName(resourceName)
+// coveringSubresource returns the keys of keys authorizing subject for
+// subresource of their targets.
+func (s *AuthStore) coveringSubresource(keys sets.Set[string], subject v1a1.Subject, subresource string) sets.Set[string] {
+	covering := sets.New[string]()
+	for key := range keys {
+		if s.provenance[key].subresources[subject].Has(subresource) {
+			covering.Insert(key)
+		}
+	}
+	return covering
+}
 
+// BlockedTargets returns, for the name of every ReferencePolicy, the targets
+// it keeps from being granted for each from-to-for key.
+func (s *AuthStore) BlockedTargets() map[string]map[string][]types.NamespacedName {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
+	blocked := map[string]map[string][]types.NamespacedName{}
+	for key, kp := range s.provenance {
+		for target, policies := range kp.blocked {
+			for policy := range policies {
+				if _, ok := blocked[policy]; !ok {
+					blocked[policy] = map[string][]types.NamespacedName{}
+				}
+				blocked[policy][key] = append(blocked[policy][key], target)
+			}
+		}
+	}
+	for _, keys := range blocked {
+		for _, targets := range keys {
+			sort.Slice(targets, func(i, j int) bool { return lessNamespacedName(targets[i], targets[j]) })
+		}
+	}
+	return blocked
+}
+
+// ReplaceGraphKey atomically replaces everything computed for a from-to-for
+// key with the given edges and provenance, and increments the generation of
+// the graph.
+func (s *AuthStore) ReplaceGraphKey(key string, edges []Edge, provenance KeyProvenance) {
+	splitedKey := strings.Split(key, ";")
+	to := TargetResourceGroup(splitedKey[1])
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.clearGraphKey(key, to)
+	kp := &keyProvenance{
+		consumers:              make(map[v1a1.Subject]sets.Set[string], len(provenance.Consumers)),
+		subresources:           make(map[v1a1.Subject]sets.Set[string], len(provenance.Subresources)),
+		clusterReferenceGrants: sets.New(provenance.ClusterReferenceGrants...),
+		sources:                make(map[types.NamespacedName]sets.Set[types.NamespacedName]),
+		referenceGrants:        make(map[types.NamespacedName]sets.Set[types.NamespacedName]),
+		ungranted:              make(map[types.NamespacedName]sets.Set[types.NamespacedName]),
+		blocked:                make(map[types.NamespacedName]sets.Set[string], len(provenance.Blocked)),
+	}
+	for subject, consumers := range provenance.Consumers {
+		kp.consumers[subject] = sets.New(consumers...)
+	}
+	for subject, subresources := range provenance.Subresources {
+		kp.subresources[subject] = sets.New(subresources...)
+	}
+	for _, edge := range provenance.Ungranted {
+		if _, ok := kp.ungranted[edge.Target]; !ok {
+			kp.ungranted[edge.Target] = make(sets.Set[types.NamespacedName])
+		}
+		kp.ungranted[edge.Target].Insert(edge.Sources...)
+	}
+	for target, policies := range provenance.Blocked {
+		kp.blocked[target] = sets.New(policies...)
+	}
+	s.provenance[key] = kp
+
+	for _, edge := range edges {
+		s.upsertGrant(key, to, edge)
+	}
+	s.generation++
+}
+
+func (s *AuthStore) upsertGrant(key string, to TargetResourceGroup, edge Edge) {
 	if _, ok := s.graph[key]; !ok {
 		s.graph[key] = make(map[types.NamespacedName]sets.Set[v1a1.Subject])
 	}
-	if _, ok := s.graph[key][resourceName]; !ok {
-		s.graph[key][resourceName] = make(sets.Set[v1a1.Subject])
+	if _, ok := s.graph[key][edge.Target]; !ok {
+		s.graph[key][edge.Target] = make(sets.Set[v1a1.Subject])
+		s.provenance[key].sources[edge.Target] = make(sets.Set[types.NamespacedName])
+		s.provenance[key].referenceGrants[edge.Target] = make(sets.Set[types.NamespacedName])
 	}
-	s.graph[key][resourceName].Insert(subjects...)
+	s.graph[key][edge.Target].Insert(edge.Subjects...)
+	s.provenance[key].sources[edge.Target].Insert(edge.Sources...)
+	s.provenance[key].referenceGrants[edge.Target].Insert(edge.ReferenceGrants...)
 
-	for _, subject := range subjects {
+	for _, subject := range edge.Subjects {
 		if _, ok := s.subjectIndex[subject]; !ok {
-			s.subjectIndex[subject] = make(map[TargetResourceGroup]map[types.NamespacedName]Purpose)
+			s.subjectIndex[subject] = make(map[TargetResourceGroup]map[types.NamespacedName]sets.Set[string])
 		}
 		if _, ok := s.subjectIndex[subject][to]; !ok {
-			s.subjectIndex[subject][to] = make(map[types.NamespacedName]Purpose)
+			s.subjectIndex[subject][to] = make(map[types.NamespacedName]sets.Set[string])
+		}
+		if _, ok := s.subjectIndex[subject][to][edge.Target]; !ok {
+			s.subjectIndex[subject][to][edge.Target] = make(sets.Set[string])
 		}
-		s.subjectIndex[subject][to][resourceName] = purpose
+		s.subjectIndex[subject][to][edge.Target].Insert(key)
 	}
 }
 
-func (s *AuthStore) ClearGraphKey(key string) {
-	splitedKey := strings.Split(key, ";")
-	to, purpose := TargetResourceGroup(splitedKey[1]), Purpose(splitedKey[2])
-
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-	delete(s.graph, key)
+func (s *AuthStore) clearGraphKey(key string, to TargetResourceGroup) {
 	// Update subject index
-	for subject, targetGroupResourceMap := range s.subjectIndex {
-		for tgr, tnnMap := range targetGroupResourceMap {
-			if tgr != to {
-				continue
-			}
-			for tnn, p := range tnnMap {
-				if p == purpose {
-					// If the key is found in the subjectIndex, remove the entry
-					delete(s.subjectIndex[subject][tgr], tnn)
-				}
+	for tnn, subjects := range s.graph[key] {
+		for subject := range subjects {
+			keys := s.subjectIndex[subject][to][tnn]
+			// If the key is found in the subjectIndex, remove the entry
+			keys.Delete(key)
+			if keys.Len() == 0 {
+				delete(s.subjectIndex[subject][to], tnn)
 			}
 			// If the map under a particular To becomes empty after deletion,
 			// remove the entire To entry from the subjectIndex
-			if len(s.subjectIndex[subject][tgr]) == 0 {
-				delete(s.subjectIndex[subject], tgr)
+			if len(s.subjectIndex[subject][to]) == 0 {
+				delete(s.subjectIndex[subject], to)
+			}
+			// If the map under a particular subject becomes empty after deletion,
+			// remove the entire subject entry from the subjectIndex
+			if len(s.subjectIndex[subject]) == 0 {
+				delete(s.subjectIndex, subject)
 			}
 		}
-		// If the map under a particular subject becomes empty after deletion,
-		// remove the entire subject entry from the subjectIndex
-		if len(s.subjectIndex[subject]) == 0 {
-			delete(s.subjectIndex, subject)
-		}
 	}
+	delete(s.graph, key)
+	delete(s.provenance, key)
+}
+
+func sortedNamespacedNames(nns sets.Set[types.NamespacedName]) []types.NamespacedName {
+	out := nns.UnsortedList()
+	sort.Slice(out, func(i, j int) bool { return lessNamespacedName(out[i], out[j]) })
+	return out
+}
+
+func lessNamespacedName(a, b types.NamespacedName) bool {
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
 }
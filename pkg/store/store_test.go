@@ -0,0 +1,220 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	v1a1 "sigs.k8s.io/referencegrant-poc/apis/v1alpha1"
+)
+
+func TestCheckAuthzSubresources(t *testing.T) {
+	alice := v1a1.Subject{Kind: "User", Name: "alice"}
+	bob := v1a1.Subject{Kind: "User", Name: "bob"}
+	target := types.NamespacedName{Namespace: "demo", Name: "tls"}
+
+	s := NewAuthStore()
+	s.ReplaceGraphKey("gateway.networking.k8s.io/gateways;/secrets;tls-serving",
+		[]Edge{{Target: target, Subjects: []v1a1.Subject{alice, bob}}},
+		KeyProvenance{
+			Consumers:    map[v1a1.Subject][]string{alice: {"alice"}, bob: {"bob"}},
+			Subresources: map[v1a1.Subject][]string{alice: {"status"}},
+		})
+
+	tests := []struct {
+		name        string
+		user        string
+		subresource string
+		want        bool
+	}{
+		{name: "resource", user: "bob", want: true},
+		{name: "subresource not covered by default", user: "bob", subresource: "status", want: false},
+		{name: "covered subresource", user: "alice", subresource: "status", want: true},
+		{name: "other subresource", user: "alice", subresource: "proxy", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := s.CheckAuthz(authorizationv1.SubjectAccessReview{
+				Spec: authorizationv1.SubjectAccessReviewSpec{
+					User: tt.user,
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Verb:        "get",
+						Resource:    "secrets",
+						Subresource: tt.subresource,
+						Namespace:   target.Namespace,
+						Name:        target.Name,
+					},
+				},
+			})
+			if err != nil {
+				t.Fatalf("CheckAuthz() error = %v", err)
+			}
+			if decision.Allowed != tt.want {
+				t.Errorf("CheckAuthz() allowed = %v, want %v", decision.Allowed, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAuthzClusterScoped(t *testing.T) {
+	alice := v1a1.Subject{Kind: "User", Name: "alice"}
+	s := NewAuthStore()
+	s.ReplaceGraphKey("gateway.networking.k8s.io/gateways;networking.k8s.io/ingressclasses;parameters",
+		[]Edge{{Target: types.NamespacedName{Name: "public"}, Subjects: []v1a1.Subject{alice}}},
+		KeyProvenance{Consumers: map[v1a1.Subject][]string{alice: {"alice"}}})
+	s.ReplaceGraphKey("gateway.networking.k8s.io/gateways;/secrets;tls-serving",
+		[]Edge{{Target: types.NamespacedName{Namespace: "demo", Name: "public"}, Subjects: []v1a1.Subject{alice}}},
+		KeyProvenance{Consumers: map[v1a1.Subject][]string{alice: {"alice"}}})
+
+	tests := []struct {
+		name  string
+		attrs authorizationv1.ResourceAttributes
+		want  bool
+	}{
+		{
+			name:  "cluster-scoped target",
+			attrs: authorizationv1.ResourceAttributes{Verb: "get", Group: "networking.k8s.io", Resource: "ingressclasses", Name: "public"},
+			want:  true,
+		},
+		{
+			name:  "namespaced target",
+			attrs: authorizationv1.ResourceAttributes{Verb: "get", Resource: "secrets", Namespace: "demo", Name: "public"},
+			want:  true,
+		},
+		{
+			name: "namespaced target across all namespaces",
+			attrs: authorizationv1.ResourceAttributes{Verb: "list", Resource: "secrets", FieldSelector: &authorizationv1.FieldSelectorAttributes{
+				Requirements: []metav1.FieldSelectorRequirement{{Key: "metadata.name", Operator: metav1.FieldSelectorOpIn, Values: []string{"public"}}},
+			}},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attrs := tt.attrs
+			decision, err := s.CheckAuthz(authorizationv1.SubjectAccessReview{
+				Spec: authorizationv1.SubjectAccessReviewSpec{User: "alice", ResourceAttributes: &attrs},
+			})
+			if err != nil {
+				t.Fatalf("CheckAuthz() error = %v", err)
+			}
+			if decision.Allowed != tt.want {
+				t.Errorf("CheckAuthz() allowed = %v, want %v", decision.Allowed, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAuthzVerbs(t *testing.T) {
+	alice := v1a1.Subject{Kind: "User", Name: "alice"}
+	target := types.NamespacedName{Namespace: "demo", Name: "tls"}
+	s := NewAuthStore()
+	s.ReplaceGraphKey("gateway.networking.k8s.io/gateways;/secrets;tls-serving",
+		[]Edge{{Target: target, Subjects: []v1a1.Subject{alice}}},
+		KeyProvenance{Consumers: map[v1a1.Subject][]string{alice: {"alice"}}})
+
+	tests := []struct {
+		verb string
+		want bool
+	}{
+		{verb: "get", want: true},
+		{verb: "update", want: false},
+		{verb: "patch", want: false},
+		{verb: "delete", want: false},
+		{verb: "create", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.verb, func(t *testing.T) {
+			decision, err := s.CheckAuthz(authorizationv1.SubjectAccessReview{
+				Spec: authorizationv1.SubjectAccessReviewSpec{
+					User: "alice",
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Verb:      tt.verb,
+						Resource:  "secrets",
+						Namespace: target.Namespace,
+						Name:      target.Name,
+					},
+				},
+			})
+			if err != nil {
+				t.Fatalf("CheckAuthz() error = %v", err)
+			}
+			if decision.Allowed != tt.want {
+				t.Errorf("CheckAuthz() allowed = %v, want %v", decision.Allowed, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAuthzFieldSelector(t *testing.T) {
+	alice := v1a1.Subject{Kind: "User", Name: "alice"}
+	s := NewAuthStore()
+	s.ReplaceGraphKey("gateway.networking.k8s.io/gateways;/secrets;tls-serving",
+		[]Edge{
+			{Target: types.NamespacedName{Namespace: "demo", Name: "tls"}, Subjects: []v1a1.Subject{alice}},
+			{Target: types.NamespacedName{Namespace: "demo", Name: "other-tls"}, Subjects: []v1a1.Subject{alice}},
+		},
+		KeyProvenance{Consumers: map[v1a1.Subject][]string{alice: {"alice"}}})
+
+	in := func(values ...string) metav1.FieldSelectorRequirement {
+		return metav1.FieldSelectorRequirement{Key: "metadata.name", Operator: metav1.FieldSelectorOpIn, Values: values}
+	}
+	tests := []struct {
+		name         string
+		verb         string
+		requirements []metav1.FieldSelectorRequirement
+		want         bool
+	}{
+		{name: "granted name", verb: "list", requirements: []metav1.FieldSelectorRequirement{in("tls")}, want: true},
+		{name: "granted names", verb: "watch", requirements: []metav1.FieldSelectorRequirement{in("tls", "other-tls")}, want: true},
+		{name: "partly granted names", verb: "list", requirements: []metav1.FieldSelectorRequirement{in("tls", "private")}, want: false},
+		{
+			name:         "intersection of granted names",
+			verb:         "list",
+			requirements: []metav1.FieldSelectorRequirement{in("tls", "private"), in("tls", "other-tls")},
+			want:         true,
+		},
+		{
+			name:         "empty intersection",
+			verb:         "list",
+			requirements: []metav1.FieldSelectorRequirement{in("tls"), in("other-tls")},
+			want:         false,
+		},
+		{name: "no selector", verb: "list", want: false},
+		{name: "not list or watch", verb: "get", requirements: []metav1.FieldSelectorRequirement{in("tls")}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attrs := &authorizationv1.ResourceAttributes{Verb: tt.verb, Resource: "secrets", Namespace: "demo"}
+			if tt.requirements != nil {
+				attrs.FieldSelector = &authorizationv1.FieldSelectorAttributes{Requirements: tt.requirements}
+			}
+			decision, err := s.CheckAuthz(authorizationv1.SubjectAccessReview{
+				Spec: authorizationv1.SubjectAccessReviewSpec{User: "alice", ResourceAttributes: attrs},
+			})
+			if err != nil {
+				t.Fatalf("CheckAuthz() error = %v", err)
+			}
+			if decision.Allowed != tt.want {
+				t.Errorf("CheckAuthz() allowed = %v, want %v", decision.Allowed, tt.want)
+			}
+		})
+	}
+}
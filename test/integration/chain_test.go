@@ -0,0 +1,108 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/referencegrant-poc/cmd/controller"
+	"sigs.k8s.io/referencegrant-poc/pkg/handlers"
+)
+
+// chain has Frontends referencing ListenerSets referencing Secrets, the
+// demo controller only consuming Frontends.
+const chain = `
+apiVersion: reference.authorization.k8s.io/v1alpha1
+kind: ClusterReferenceGrant
+metadata:
+  name: frontends
+from: {group: example.com, resource: frontends}
+versions:
+- version: v1
+  references:
+  - path: "$.spec.listenerSetRefs[*]"
+    to: {group: gateway.networking.x-k8s.io, resource: listenersets}
+    for: listeners
+---
+apiVersion: reference.authorization.k8s.io/v1alpha1
+kind: ClusterReferenceGrant
+metadata:
+  name: listenersets
+from: {group: gateway.networking.x-k8s.io, resource: listenersets}
+versions:
+- version: v1alpha1
+  references:
+  - path: "$.spec.listeners[*].tls.certificateRefs[*]"
+    to: {group: "", resource: secrets}
+    for: tls-serving
+---
+apiVersion: reference.authorization.k8s.io/v1alpha1
+kind: ClusterReferenceConsumer
+metadata:
+  name: demo-controller-frontends
+subject: {kind: ServiceAccount, name: demo-controller, namespace: demo}
+references:
+- from: {group: example.com, resource: frontends}
+  to: {group: gateway.networking.x-k8s.io, resource: listenersets}
+  for: listeners
+---
+apiVersion: example.com/v1
+kind: Frontend
+metadata:
+  name: frontend
+  namespace: demo
+spec:
+  listenerSetRefs:
+  - name: listeners
+---
+apiVersion: gateway.networking.x-k8s.io/v1alpha1
+kind: ListenerSet
+metadata:
+  name: listeners
+  namespace: demo
+spec:
+  listeners:
+  - name: https
+    tls:
+      certificateRefs:
+      - name: listener-tls
+`
+
+// TestChainRevocation checks that deleting an object in the middle of a chain
+// of references revokes access to the targets downstream of it.
+func TestChainRevocation(t *testing.T) {
+	cfg := startEnvironment(t)
+	authStore := startController(t, cfg, controller.Options{Mode: controller.ModeWebhook})
+	c := newClient(t, cfg)
+	handler := handlers.AuthzHandler(authStore, handlers.AuthzOptions{})
+
+	createNamespace(t, c, "demo")
+	create(t, c, chain)
+	expectAuthorized(t, handler, demoController, "demo", "listener-tls", true)
+
+	listenerSet := &unstructured.Unstructured{}
+	listenerSet.SetGroupVersionKind(schema.GroupVersionKind{Group: "gateway.networking.x-k8s.io", Version: "v1alpha1", Kind: "ListenerSet"})
+	listenerSet.SetNamespace("demo")
+	listenerSet.SetName("listeners")
+	if err := c.Delete(context.Background(), listenerSet); err != nil {
+		t.Fatalf("failed to delete the ListenerSet: %v", err)
+	}
+	expectAuthorized(t, handler, demoController, "demo", "listener-tls", false)
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/referencegrant-poc/cmd/controller"
+	"sigs.k8s.io/referencegrant-poc/pkg/handlers"
+)
+
+// gatewayClasses has a cluster-scoped GatewayClass referencing a Secret of
+// the demo namespace, granted by a ReferenceGrant from the empty namespace.
+const gatewayClasses = `
+apiVersion: reference.authorization.k8s.io/v1alpha1
+kind: ClusterReferenceGrant
+metadata:
+  name: gatewayclasses
+from: {group: gateway.networking.k8s.io, resource: gatewayclasses}
+versions:
+- version: v1
+  references:
+  - path: "$.spec.parametersRef"
+    to: {group: "", resource: secrets}
+    for: parameters
+---
+apiVersion: reference.authorization.k8s.io/v1alpha1
+kind: ClusterReferenceConsumer
+metadata:
+  name: demo-controller-gatewayclasses
+subject: {kind: ServiceAccount, name: demo-controller, namespace: demo}
+references:
+- from: {group: gateway.networking.k8s.io, resource: gatewayclasses}
+  to: {group: "", resource: secrets}
+  for: parameters
+---
+apiVersion: reference.authorization.k8s.io/v1alpha1
+kind: ReferenceGrant
+metadata:
+  name: gatewayclasses
+  namespace: demo
+from: {group: gateway.networking.k8s.io, resource: gatewayclasses}
+to: {group: "", resource: secrets, names: [class-parameters]}
+for: parameters
+---
+apiVersion: gateway.networking.k8s.io/v1
+kind: GatewayClass
+metadata:
+  name: demo
+spec:
+  controllerName: example.com/demo-controller
+  parametersRef: {group: "", kind: Secret, name: class-parameters, namespace: demo}
+`
+
+// TestClusterScopedSource checks that deleting a cluster-scoped From object
+// removes the edges of its references.
+func TestClusterScopedSource(t *testing.T) {
+	cfg := startEnvironment(t)
+	authStore := startController(t, cfg, controller.Options{Mode: controller.ModeWebhook})
+	c := newClient(t, cfg)
+	handler := handlers.AuthzHandler(authStore, handlers.AuthzOptions{})
+
+	createNamespace(t, c, "demo")
+	create(t, c, gatewayClasses)
+	expectAuthorized(t, handler, demoController, "demo", "class-parameters", true)
+
+	gatewayClass := &unstructured.Unstructured{}
+	gatewayClass.SetGroupVersionKind(schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "GatewayClass"})
+	gatewayClass.SetName("demo")
+	if err := c.Delete(context.Background(), gatewayClass); err != nil {
+		t.Fatalf("failed to delete the GatewayClass: %v", err)
+	}
+	expectAuthorized(t, handler, demoController, "demo", "class-parameters", false)
+
+	key := "gateway.networking.k8s.io/gatewayclasses;/secrets;parameters"
+	if edges := authStore.GetGraphKey(key); len(edges) != 0 {
+		t.Errorf("expected no edges for %s, got %v", key, edges)
+	}
+}
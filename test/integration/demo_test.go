@@ -0,0 +1,276 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package integration runs the demo of demo/README.md against a local
+// apiserver and etcd started by envtest. The tests are skipped unless
+// KUBEBUILDER_ASSETS points to the envtest binaries, e.g.
+//
+//	KUBEBUILDER_ASSETS=$(setup-envtest use -p path 1.31.x) go test ./test/integration/
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/referencegrant-poc/cmd/controller"
+	"sigs.k8s.io/referencegrant-poc/pkg/handlers"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+const (
+	manifests      = "../../demo/manifests"
+	demoController = "system:serviceaccount:demo:demo-controller"
+	timeout        = 30 * time.Second
+)
+
+// startEnvironment starts envtest with the CRDs of this repository and of the
+// Gateway API until the test ends.
+func startEnvironment(t *testing.T) *rest.Config {
+	t.Helper()
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set")
+	}
+
+	env := &envtest.Environment{
+		CRDDirectoryPaths: []string{
+			filepath.Join("..", "..", "config", "crd"),
+			filepath.Join("testdata", "gateway-api"),
+			filepath.Join("testdata", "crds"),
+		},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := env.Start()
+	if err != nil {
+		t.Fatalf("failed to start envtest: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := env.Stop(); err != nil {
+			t.Errorf("failed to stop envtest: %v", err)
+		}
+	})
+	return cfg
+}
+
+// startController runs a controller against cfg until the test ends.
+func startController(t *testing.T, cfg *rest.Config, opts controller.Options) *store.AuthStore {
+	t.Helper()
+	authStore := store.NewAuthStore()
+	c, err := controller.New(cfg, authStore, opts)
+	if err != nil {
+		t.Fatalf("failed to create controller: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- c.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("controller failed: %v", err)
+		}
+	})
+	return authStore
+}
+
+func newClient(t *testing.T, cfg *rest.Config) client.Client {
+	t.Helper()
+	c, err := client.New(cfg, client.Options{})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return c
+}
+
+func createNamespace(t *testing.T, c client.Client, name string) {
+	t.Helper()
+	if err := c.Create(context.Background(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}); err != nil {
+		t.Fatalf("failed to create namespace: %v", err)
+	}
+}
+
+// readManifest decodes the objects of a demo manifest.
+func readManifest(t *testing.T, name string) []*unstructured.Unstructured {
+	t.Helper()
+	f, err := os.Open(filepath.Join(manifests, name))
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", name, err)
+	}
+	defer f.Close()
+	return decode(t, f, name)
+}
+
+// decode decodes the YAML or JSON objects of r.
+func decode(t *testing.T, r io.Reader, name string) []*unstructured.Unstructured {
+	t.Helper()
+	objs := []*unstructured.Unstructured{}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objs
+			}
+			t.Fatalf("failed to decode %s: %v", name, err)
+		}
+		if len(obj.Object) > 0 {
+			objs = append(objs, obj)
+		}
+	}
+}
+
+// create creates the objects of an inline manifest.
+func create(t *testing.T, c client.Client, manifest string) {
+	t.Helper()
+	for _, obj := range decode(t, strings.NewReader(manifest), t.Name()) {
+		if err := c.Create(context.Background(), obj); err != nil {
+			t.Fatalf("failed to create %s %s: %v", obj.GetKind(), obj.GetName(), err)
+		}
+	}
+}
+
+// apply creates the objects of a demo manifest, like `kubectl apply -f`.
+func apply(t *testing.T, c client.Client, name string) {
+	t.Helper()
+	for _, obj := range readManifest(t, name) {
+		if err := c.Create(context.Background(), obj); err != nil {
+			t.Fatalf("failed to create %s %s from %s: %v", obj.GetKind(), obj.GetName(), name, err)
+		}
+	}
+}
+
+// remove deletes the objects of a demo manifest, like `kubectl delete -f`.
+func remove(t *testing.T, c client.Client, name string) {
+	t.Helper()
+	for _, obj := range readManifest(t, name) {
+		if err := c.Delete(context.Background(), obj); err != nil {
+			t.Fatalf("failed to delete %s %s from %s: %v", obj.GetKind(), obj.GetName(), name, err)
+		}
+	}
+}
+
+// authorize sends a SubjectAccessReview for getting a secret to the
+// AuthzHandler, like `kubectl auth can-i get secrets/name --as user`.
+func authorize(t *testing.T, handler http.Handler, user, namespace, name string) bool {
+	t.Helper()
+	sar := authorizationv1.SubjectAccessReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "authorization.k8s.io/v1", Kind: "SubjectAccessReview"},
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User: user,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Verb:      "get",
+				Version:   "v1",
+				Resource:  "secrets",
+				Namespace: namespace,
+				Name:      name,
+			},
+		},
+	}
+	body, err := json.Marshal(sar)
+	if err != nil {
+		t.Fatalf("failed to marshal SubjectAccessReview: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/authorize", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	resp := authorizationv1.SubjectAccessReview{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal SubjectAccessReview: %v", err)
+	}
+	return resp.Status.Allowed
+}
+
+// expectAuthorized waits for the authorizer to converge on allowing, or not,
+// user to get the secret.
+func expectAuthorized(t *testing.T, handler http.Handler, user, namespace, name string, allowed bool) {
+	t.Helper()
+	err := wait.PollUntilContextTimeout(context.Background(), 100*time.Millisecond, timeout, true, func(context.Context) (bool, error) {
+		return authorize(t, handler, user, namespace, name) == allowed, nil
+	})
+	if err != nil {
+		t.Fatalf("expected allowed=%v for %s to get secrets %s/%s: %v", allowed, user, namespace, name, err)
+	}
+}
+
+// TestDemo reproduces every step of demo/README.md.
+func TestDemo(t *testing.T) {
+	cfg := startEnvironment(t)
+	authStore := startController(t, cfg, controller.Options{Mode: controller.ModeWebhook})
+	c := newClient(t, cfg)
+	handler := handlers.AuthzHandler(authStore, handlers.AuthzOptions{})
+
+	createNamespace(t, c, "demo")
+
+	steps := []struct {
+		name      string
+		user      string
+		apply     string
+		remove    string
+		namespace string
+		secret    string
+		allowed   bool
+	}{
+		{name: "no grants", namespace: "demo", secret: "demo-tls-secret", allowed: false},
+		{name: "grants without references", apply: "crg.yaml", namespace: "demo", secret: "demo-tls-secret", allowed: false},
+		{name: "consumer without references", apply: "crc.yaml", namespace: "demo", secret: "demo-tls-secret", allowed: false},
+		{name: "same namespace reference", apply: "gateway.yaml", namespace: "demo", secret: "demo-tls-secret", allowed: true},
+		{name: "other subjects", user: "system:serviceaccount:demo:other", namespace: "demo", secret: "demo-tls-secret", allowed: false},
+		{name: "cross namespace reference", apply: "gateway-secret-cross-ns.yaml", namespace: "default", secret: "demo-tls-secret-default", allowed: false},
+		{name: "cross namespace reference with a ReferenceGrant", apply: "referencegrant.yaml", namespace: "default", secret: "demo-tls-secret-default", allowed: true},
+		{name: "cross namespace reference removed", remove: "gateway-secret-cross-ns.yaml", namespace: "default", secret: "demo-tls-secret-default", allowed: false},
+		{name: "same namespace reference removed", remove: "gateway.yaml", namespace: "demo", secret: "demo-tls-secret", allowed: false},
+	}
+	for _, step := range steps {
+		ok := t.Run(step.name, func(t *testing.T) {
+			if step.apply != "" {
+				apply(t, c, step.apply)
+			}
+			if step.remove != "" {
+				remove(t, c, step.remove)
+			}
+			user := step.user
+			if user == "" {
+				user = demoController
+			}
+			expectAuthorized(t, handler, user, step.namespace, step.secret, step.allowed)
+		})
+		if !ok {
+			// Later steps build on earlier ones.
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/referencegrant-poc/cmd/controller"
+	"sigs.k8s.io/referencegrant-poc/pkg/handlers"
+	rbaccontroller "sigs.k8s.io/referencegrant-poc/pkg/rbac-implementation/controller"
+)
+
+// TestGrantReferenceChanged checks that changing the purpose of a reference
+// of a ClusterReferenceGrant revokes what its previous from-to-for key
+// granted, in the graph and in the generated RBAC.
+func TestGrantReferenceChanged(t *testing.T) {
+	cfg := startEnvironment(t)
+	authStore := startController(t, cfg, controller.Options{Mode: controller.ModeRBAC})
+	c := newClient(t, cfg)
+	handler := handlers.AuthzHandler(authStore, handlers.AuthzOptions{})
+
+	createNamespace(t, c, "demo")
+	for _, manifest := range []string{"crg.yaml", "crc.yaml", "gateway.yaml"} {
+		apply(t, c, manifest)
+	}
+	expectAuthorized(t, handler, demoController, "demo", "demo-tls-secret", true)
+	const key = "gateway.networking.k8s.io/gateways;/secrets;tls-serving"
+	expectRoleBindings(t, c, key, 1)
+
+	crg := &unstructured.Unstructured{}
+	crg.SetGroupVersionKind(schema.GroupVersionKind{Group: "reference.authorization.k8s.io", Version: "v1alpha1", Kind: "ClusterReferenceGrant"})
+	crg.SetName("gateways")
+	patch := []byte(`{"versions": [{"version": "v1", "references": [{"path": "$.spec.listeners[*].tls.certificateRefs[*]", "to": {"group": "", "resource": "secrets"}, "for": "tls-client"}]}]}`)
+	if err := c.Patch(context.Background(), crg, client.RawPatch(types.MergePatchType, patch)); err != nil {
+		t.Fatalf("failed to patch the ClusterReferenceGrant: %v", err)
+	}
+	expectAuthorized(t, handler, demoController, "demo", "demo-tls-secret", false)
+	expectRoleBindings(t, c, key, 0)
+}
+
+// expectRoleBindings waits for count RoleBindings to be generated for key.
+func expectRoleBindings(t *testing.T, c client.Client, key string, count int) {
+	t.Helper()
+	err := wait.PollUntilContextTimeout(context.Background(), 100*time.Millisecond, timeout, true, func(ctx context.Context) (bool, error) {
+		bindings := &rbacv1.RoleBindingList{}
+		if err := c.List(ctx, bindings, client.MatchingLabels{rbaccontroller.LabelKeyPatternName: rbaccontroller.PatternName(key)}); err != nil {
+			return false, err
+		}
+		return len(bindings.Items) == count, nil
+	})
+	if err != nil {
+		t.Fatalf("expected %d generated RoleBindings for %s: %v", count, key, err)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/referencegrant-poc/cmd/controller"
+	"sigs.k8s.io/referencegrant-poc/pkg/config"
+	rbaccontroller "sigs.k8s.io/referencegrant-poc/pkg/rbac-implementation/controller"
+	"sigs.k8s.io/referencegrant-poc/pkg/store"
+)
+
+// countingTransport counts the side-effecting requests of a replica: writes of
+// generated RBAC and of the status of ClusterReferenceGrants.
+type countingTransport struct {
+	next   http.RoundTripper
+	writes *atomic.Int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet &&
+		(strings.HasPrefix(req.URL.Path, "/apis/rbac.authorization.k8s.io/") || strings.Contains(req.URL.Path, "/clusterreferencegrants/")) {
+		t.writes.Add(1)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// TestReplicasConverge runs two replicas with leader election, and checks that
+// both build the same graph while only the leader writes the generated RBAC.
+func TestReplicasConverge(t *testing.T) {
+	cfg := startEnvironment(t)
+	c := newClient(t, cfg)
+	createNamespace(t, c, "demo")
+
+	opts := controller.Options{
+		Mode: controller.ModeRBAC,
+		LeaderElection: config.LeaderElectionConfiguration{
+			LeaderElect:       true,
+			ResourceNamespace: "default",
+			ResourceName:      "referencegrant-poc",
+			LeaseDuration:     metav1.Duration{Duration: 4 * time.Second},
+			RenewDeadline:     metav1.Duration{Duration: 3 * time.Second},
+			RetryPeriod:       metav1.Duration{Duration: time.Second},
+		},
+		SkipNameValidation: true,
+	}
+	var writes [2]atomic.Int32
+	replicas := make([]*store.AuthStore, len(writes))
+	for i := range replicas {
+		replicaCfg := rest.CopyConfig(cfg)
+		replicaCfg.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &countingTransport{next: rt, writes: &writes[i]}
+		})
+		replicas[i] = startController(t, replicaCfg, opts)
+	}
+
+	for _, manifest := range []string{"crg.yaml", "crc.yaml", "gateway.yaml", "gateway-secret-cross-ns.yaml", "referencegrant.yaml"} {
+		apply(t, c, manifest)
+	}
+
+	// The generation of the graph depends on the events each replica saw,
+	// so only the edges are compared.
+	var views [2]store.GraphView
+	err := wait.PollUntilContextTimeout(context.Background(), 100*time.Millisecond, timeout, true, func(context.Context) (bool, error) {
+		for i, replica := range replicas {
+			views[i] = replica.View(store.GraphFilter{})
+			views[i].Generation = 0
+		}
+		return len(views[0].Keys) == 1 && len(views[0].Keys[0].Edges) == 2 && cmp.Equal(views[0], views[1]), nil
+	})
+	if err != nil {
+		t.Fatalf("replicas did not converge: %v\n%s", err, cmp.Diff(views[0], views[1]))
+	}
+
+	// Only the leader materializes the graph, into one RoleBinding per
+	// namespace and set of subjects.
+	key := views[0].Keys[0].Key
+	err = wait.PollUntilContextTimeout(context.Background(), 100*time.Millisecond, timeout, true, func(ctx context.Context) (bool, error) {
+		bindings := &rbacv1.RoleBindingList{}
+		if err := c.List(ctx, bindings, client.MatchingLabels{rbaccontroller.LabelKeyPatternName: rbaccontroller.PatternName(key)}); err != nil {
+			return false, err
+		}
+		return len(bindings.Items) == 2, nil
+	})
+	if err != nil {
+		t.Fatalf("generated RoleBindings were not created: %v", err)
+	}
+
+	// Both replicas reconciled every key, but only one of them, the leader,
+	// wrote anything.
+	if got := []int32{writes[0].Load(), writes[1].Load()}; (got[0] == 0) == (got[1] == 0) {
+		t.Errorf("expected writes from exactly one replica, got %v", got)
+	}
+}